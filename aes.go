@@ -1,12 +1,18 @@
 package common
 
 import (
+	"bytes"
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
 	"errors"
 	"fmt"
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/pbkdf2"
+	"golang.org/x/crypto/scrypt"
 	"io"
 	"os"
 	"strings"
@@ -16,6 +22,188 @@ const (
 	SECRET_PREFIX = "secret:"
 )
 
+type CipherAlgorithm byte
+
+const (
+	CipherAES256GCM CipherAlgorithm = iota + 1
+	CipherChaCha20Poly1305
+)
+
+type KeyDerivation byte
+
+const (
+	// KdfNone uses the key as is, it must have a length of 32 bytes
+	KdfNone KeyDerivation = iota
+	KdfPBKDF2
+	KdfScrypt
+	KdfArgon2id
+)
+
+const (
+	aeadVersion   = byte(1)
+	aeadSaltLen   = 16
+	aeadKeyLen    = 32
+	aeadHeaderLen = 5
+)
+
+var (
+	// aeadMagic marks the versioned envelope of authenticated ciphertexts:
+	// magic(2) | version(1) | cipher(1) | kdf(1) | salt(16, not with KdfNone) | nonce | ciphertext+tag
+	aeadMagic = []byte{0xAE, 0xAD}
+
+	DefaultCipherAlgorithm = CipherAES256GCM
+	DefaultKeyDerivation   = KdfArgon2id
+)
+
+type ErrDecrypt struct {
+	Err error
+}
+
+func (e *ErrDecrypt) Error() string {
+	return fmt.Sprintf("decryption failed: %s", e.Err.Error())
+}
+
+func (e *ErrDecrypt) Unwrap() error {
+	return e.Err
+}
+
+func deriveKey(kdf KeyDerivation, passphrase []byte, salt []byte) ([]byte, error) {
+	switch kdf {
+	case KdfNone:
+		if len(passphrase) != aeadKeyLen {
+			return nil, fmt.Errorf("key must have a length of %d bytes", aeadKeyLen)
+		}
+
+		return passphrase, nil
+	case KdfPBKDF2:
+		return pbkdf2.Key(passphrase, salt, 600000, aeadKeyLen, sha256.New), nil
+	case KdfScrypt:
+		return scrypt.Key(passphrase, salt, 32768, 8, 1, aeadKeyLen)
+	case KdfArgon2id:
+		// OWASP recommended minimum configuration
+		return argon2.IDKey(passphrase, salt, 2, 19*1024, 1, aeadKeyLen), nil
+	default:
+		return nil, fmt.Errorf("unknown key derivation: %d", kdf)
+	}
+}
+
+func newAEAD(algorithm CipherAlgorithm, key []byte) (cipher.AEAD, error) {
+	switch algorithm {
+	case CipherAES256GCM:
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, err
+		}
+
+		return cipher.NewGCM(block)
+	case CipherChaCha20Poly1305:
+		return chacha20poly1305.New(key)
+	default:
+		return nil, fmt.Errorf("unknown cipher algorithm: %d", algorithm)
+	}
+}
+
+// IsAEAD returns true if the message has the envelope of an authenticated ciphertext.
+// A legacy ciphertext starts with a random IV, the chance to match the complete header is about 2^-35.
+func IsAEAD(message []byte) bool {
+	return len(message) > aeadHeaderLen &&
+		bytes.HasPrefix(message, aeadMagic) &&
+		message[2] == aeadVersion &&
+		CipherAlgorithm(message[3]) >= CipherAES256GCM && CipherAlgorithm(message[3]) <= CipherChaCha20Poly1305 &&
+		KeyDerivation(message[4]) <= KdfArgon2id
+}
+
+// EncryptAEAD encrypts and authenticates the message with the default cipher algorithm and key derivation
+func EncryptAEAD(passphrase []byte, message []byte) ([]byte, error) {
+	return EncryptAEADWith(DefaultCipherAlgorithm, DefaultKeyDerivation, passphrase, message)
+}
+
+// EncryptAEADWith encrypts and authenticates the message into a versioned envelope.
+// The envelope header is authenticated as additional data.
+func EncryptAEADWith(algorithm CipherAlgorithm, kdf KeyDerivation, passphrase []byte, message []byte) ([]byte, error) {
+	header := append(append([]byte{}, aeadMagic...), aeadVersion, byte(algorithm), byte(kdf))
+
+	var salt []byte
+
+	if kdf != KdfNone {
+		salt = make([]byte, aeadSaltLen)
+
+		_, err := io.ReadFull(rand.Reader, salt)
+		if Error(err) {
+			return nil, err
+		}
+	}
+
+	key, err := deriveKey(kdf, passphrase, salt)
+	if Error(err) {
+		return nil, err
+	}
+
+	aead, err := newAEAD(algorithm, key)
+	if Error(err) {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+
+	_, err = io.ReadFull(rand.Reader, nonce)
+	if Error(err) {
+		return nil, err
+	}
+
+	header = append(header, salt...)
+
+	cipherText := make([]byte, 0, len(header)+len(nonce)+len(message)+aead.Overhead())
+	cipherText = append(cipherText, header...)
+	cipherText = append(cipherText, nonce...)
+
+	return aead.Seal(cipherText, nonce, message, header), nil
+}
+
+// DecryptAEAD verifies and decrypts a message encrypted by EncryptAEAD
+func DecryptAEAD(passphrase []byte, message []byte) ([]byte, error) {
+	if !IsAEAD(message) {
+		return nil, &ErrDecrypt{Err: fmt.Errorf("unknown ciphertext format")}
+	}
+
+	algorithm := CipherAlgorithm(message[3])
+	kdf := KeyDerivation(message[4])
+
+	headerLen := aeadHeaderLen
+	if kdf != KdfNone {
+		headerLen += aeadSaltLen
+	}
+
+	if len(message) < headerLen {
+		return nil, &ErrDecrypt{Err: fmt.Errorf("ciphertext too short")}
+	}
+
+	header := message[:headerLen]
+
+	key, err := deriveKey(kdf, passphrase, header[aeadHeaderLen:])
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	aead, err := newAEAD(algorithm, key)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	if len(message) < headerLen+aead.NonceSize()+aead.Overhead() {
+		return nil, &ErrDecrypt{Err: fmt.Errorf("ciphertext too short")}
+	}
+
+	nonce := message[headerLen : headerLen+aead.NonceSize()]
+
+	plainText, err := aead.Open(nil, nonce, message[headerLen+aead.NonceSize():], header)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	return plainText, nil
+}
+
 func Encrypt(key []byte, message []byte) ([]byte, error) {
 	block, err := aes.NewCipher(key)
 	if Error(err) {
@@ -63,6 +251,7 @@ func IsEncrypted(password string) bool {
 	return strings.HasPrefix(password, SECRET_PREFIX)
 }
 
+// DecryptString decrypts authenticated and legacy (unauthenticated AES-CFB) "secret:" values
 func DecryptString(key []byte, txt string) (string, error) {
 	if IsEncrypted(txt) {
 		txt = txt[len(SECRET_PREFIX):]
@@ -72,6 +261,18 @@ func DecryptString(key []byte, txt string) (string, error) {
 			return "", err
 		}
 
+		// no fallback to the legacy decryption for a failed authentication, the unauthenticated
+		// AES-CFB would return garbage for a wrong key instead of an error
+
+		if IsAEAD(ba) {
+			s, err := DecryptAEAD(key, ba)
+			if Error(err) {
+				return "", err
+			}
+
+			return string(s), nil
+		}
+
 		s, err := Decrypt(key, ba)
 		if Error(err) {
 			return "", err
@@ -83,9 +284,10 @@ func DecryptString(key []byte, txt string) (string, error) {
 	}
 }
 
+// EncryptString encrypts a text to an authenticated "secret:" value
 func EncryptString(key []byte, txt string) (string, error) {
 	if !IsEncrypted(txt) {
-		s, err := Encrypt(key, []byte(txt))
+		s, err := EncryptAEAD(key, []byte(txt))
 
 		return SECRET_PREFIX + base64.StdEncoding.EncodeToString(s), err
 	} else {
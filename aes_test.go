@@ -1,7 +1,9 @@
 package common
 
 import (
+	"encoding/base64"
 	"github.com/stretchr/testify/require"
+	"slices"
 	"strings"
 	"testing"
 )
@@ -20,3 +22,66 @@ func TestEncrypt(t *testing.T) {
 
 	require.Equal(t, txt, decrypted)
 }
+
+func TestEncryptAEAD(t *testing.T) {
+	txt := []byte("Hello world!")
+
+	for _, algorithm := range []CipherAlgorithm{CipherAES256GCM, CipherChaCha20Poly1305} {
+		for _, kdf := range []KeyDerivation{KdfNone, KdfPBKDF2, KdfScrypt, KdfArgon2id} {
+			key := []byte("any passphrase")
+			if kdf == KdfNone {
+				key = RndBytes(32)
+			}
+
+			encrypted, err := EncryptAEADWith(algorithm, kdf, key, txt)
+			require.NoError(t, err)
+			require.True(t, IsAEAD(encrypted))
+
+			decrypted, err := DecryptAEAD(key, encrypted)
+			require.NoError(t, err)
+			require.Equal(t, txt, decrypted)
+
+			// wrong key
+
+			_, err = DecryptAEAD([]byte("wrong passphrase, wrong length!!"), encrypted)
+			require.ErrorAs(t, err, new(*ErrDecrypt))
+
+			// tampered ciphertext and tampered header
+
+			for _, index := range []int{len(encrypted) - 1, 3} {
+				tampered := slices.Clone(encrypted)
+				tampered[index] ^= 0x01
+
+				_, err = DecryptAEAD(key, tampered)
+				require.Error(t, err)
+			}
+		}
+	}
+}
+
+func TestDecryptLegacy(t *testing.T) {
+	key := RndBytes(16)
+
+	txt := "Hello world!"
+
+	legacy, err := Encrypt(key, []byte(txt))
+	require.NoError(t, err)
+
+	decrypted, err := DecryptString(key, SECRET_PREFIX+base64.StdEncoding.EncodeToString(legacy))
+	require.NoError(t, err)
+	require.Equal(t, txt, decrypted)
+
+	// a new encrypted value must be authenticated
+
+	encrypted, err := EncryptString(key, txt)
+	require.NoError(t, err)
+
+	ba, err := base64.StdEncoding.DecodeString(encrypted[len(SECRET_PREFIX):])
+	require.NoError(t, err)
+	require.True(t, IsAEAD(ba))
+
+	// a wrong key of a legacy key size is an error
+
+	_, err = DecryptString(RndBytes(16), encrypted)
+	require.Error(t, err)
+}
@@ -0,0 +1,304 @@
+package common
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+const (
+	aeadStreamVersion   = byte(1)
+	aeadStreamChunkSize = 64 * 1024
+	aeadStreamFinalFlag = uint32(1 << 31)
+)
+
+var (
+	// aeadStreamMagic marks the header of an encrypted stream:
+	// magic(2) | version(1) | cipher(1) | kdf(1) | salt(16, not with KdfNone) | nonce prefix
+	// followed by chunks: length with final flag(4) | ciphertext+tag
+	// Every chunk nonce is the nonce prefix | chunk counter(4) | final flag(1), so reordering,
+	// truncation and appending of chunks is detected.
+	aeadStreamMagic = []byte{0xAE, 0x5E}
+)
+
+type encryptingWriter struct {
+	w       io.Writer
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	counter uint32
+	buf     []byte
+	closed  bool
+}
+
+type decryptingReader struct {
+	r       io.Reader
+	aead    cipher.AEAD
+	header  []byte
+	prefix  []byte
+	counter uint32
+	buf     bytes.Buffer
+	final   bool
+}
+
+// NewEncryptingWriter encrypts everything written in authenticated chunks with the default cipher algorithm and key derivation.
+// Close must be called to write the final chunk, the underlying writer is not closed.
+func NewEncryptingWriter(w io.Writer, passphrase []byte) (io.WriteCloser, error) {
+	return NewEncryptingWriterWith(w, DefaultCipherAlgorithm, DefaultKeyDerivation, passphrase)
+}
+
+func NewEncryptingWriterWith(w io.Writer, algorithm CipherAlgorithm, kdf KeyDerivation, passphrase []byte) (io.WriteCloser, error) {
+	header := append(append([]byte{}, aeadStreamMagic...), aeadStreamVersion, byte(algorithm), byte(kdf))
+
+	var salt []byte
+
+	if kdf != KdfNone {
+		salt = make([]byte, aeadSaltLen)
+
+		_, err := io.ReadFull(rand.Reader, salt)
+		if Error(err) {
+			return nil, err
+		}
+	}
+
+	key, err := deriveKey(kdf, passphrase, salt)
+	if Error(err) {
+		return nil, err
+	}
+
+	aead, err := newAEAD(algorithm, key)
+	if Error(err) {
+		return nil, err
+	}
+
+	prefix := make([]byte, aead.NonceSize()-5)
+
+	_, err = io.ReadFull(rand.Reader, prefix)
+	if Error(err) {
+		return nil, err
+	}
+
+	header = append(header, salt...)
+	header = append(header, prefix...)
+
+	_, err = WriteFully(w, header)
+	if Error(err) {
+		return nil, err
+	}
+
+	return &encryptingWriter{
+		w:      w,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+		buf:    make([]byte, 0, aeadStreamChunkSize),
+	}, nil
+}
+
+func streamNonce(prefix []byte, counter uint32, final bool) []byte {
+	nonce := make([]byte, 0, len(prefix)+5)
+	nonce = append(nonce, prefix...)
+	nonce = binary.BigEndian.AppendUint32(nonce, counter)
+
+	if final {
+		return append(nonce, 1)
+	}
+
+	return append(nonce, 0)
+}
+
+func (ew *encryptingWriter) writeChunk(final bool) error {
+	if ew.counter == ^uint32(0) {
+		return fmt.Errorf("too many chunks for encrypted stream")
+	}
+
+	cipherText := ew.aead.Seal(nil, streamNonce(ew.prefix, ew.counter, final), ew.buf, ew.header)
+
+	length := uint32(len(cipherText))
+	if final {
+		length |= aeadStreamFinalFlag
+	}
+
+	_, err := WriteFully(ew.w, binary.BigEndian.AppendUint32(nil, length))
+	if err != nil {
+		return err
+	}
+
+	_, err = WriteFully(ew.w, cipherText)
+	if err != nil {
+		return err
+	}
+
+	ew.counter++
+	ew.buf = ew.buf[:0]
+
+	return nil
+}
+
+func (ew *encryptingWriter) Write(p []byte) (int, error) {
+	if ew.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	n := 0
+
+	for len(p) > 0 {
+		// a full chunk is only written if there is more data so that the last chunk is always written by Close
+
+		if len(ew.buf) == aeadStreamChunkSize {
+			err := ew.writeChunk(false)
+			if err != nil {
+				return n, err
+			}
+		}
+
+		c := copy(ew.buf[len(ew.buf):aeadStreamChunkSize], p)
+
+		ew.buf = ew.buf[:len(ew.buf)+c]
+		p = p[c:]
+		n += c
+	}
+
+	return n, nil
+}
+
+func (ew *encryptingWriter) Close() error {
+	if ew.closed {
+		return nil
+	}
+
+	ew.closed = true
+
+	return ew.writeChunk(true)
+}
+
+// NewDecryptingReader verifies and decrypts a stream written by NewEncryptingWriter
+func NewDecryptingReader(r io.Reader, passphrase []byte) (io.Reader, error) {
+	header := make([]byte, aeadHeaderLen)
+
+	_, err := io.ReadFull(r, header)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	if !bytes.HasPrefix(header, aeadStreamMagic) || header[2] != aeadStreamVersion {
+		return nil, &ErrDecrypt{Err: fmt.Errorf("unknown encrypted stream format")}
+	}
+
+	algorithm := CipherAlgorithm(header[3])
+	kdf := KeyDerivation(header[4])
+
+	var salt []byte
+
+	if kdf != KdfNone {
+		salt = make([]byte, aeadSaltLen)
+
+		_, err := io.ReadFull(r, salt)
+		if err != nil {
+			return nil, &ErrDecrypt{Err: err}
+		}
+	}
+
+	key, err := deriveKey(kdf, passphrase, salt)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	aead, err := newAEAD(algorithm, key)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	prefix := make([]byte, aead.NonceSize()-5)
+
+	_, err = io.ReadFull(r, prefix)
+	if err != nil {
+		return nil, &ErrDecrypt{Err: err}
+	}
+
+	header = append(header, salt...)
+	header = append(header, prefix...)
+
+	return &decryptingReader{
+		r:      r,
+		aead:   aead,
+		header: header,
+		prefix: prefix,
+	}, nil
+}
+
+func (dr *decryptingReader) readChunk() error {
+	ba := make([]byte, 4)
+
+	_, err := io.ReadFull(dr.r, ba)
+	if err != nil {
+		// a missing final chunk means the stream has been truncated
+
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return &ErrDecrypt{Err: err}
+	}
+
+	length := binary.BigEndian.Uint32(ba)
+	final := length&aeadStreamFinalFlag != 0
+	length &^= aeadStreamFinalFlag
+
+	if int(length) > aeadStreamChunkSize+dr.aead.Overhead() {
+		return &ErrDecrypt{Err: fmt.Errorf("invalid chunk length: %d", length)}
+	}
+
+	cipherText := make([]byte, length)
+
+	_, err = io.ReadFull(dr.r, cipherText)
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+
+		return &ErrDecrypt{Err: err}
+	}
+
+	plainText, err := dr.aead.Open(cipherText[:0], streamNonce(dr.prefix, dr.counter, final), cipherText, dr.header)
+	if err != nil {
+		return &ErrDecrypt{Err: err}
+	}
+
+	// data appended after the final chunk is not authenticated
+
+	if final {
+		n, err := io.ReadFull(dr.r, ba[:1])
+		if n > 0 {
+			return &ErrDecrypt{Err: fmt.Errorf("data after final chunk")}
+		}
+
+		if err != io.EOF {
+			return &ErrDecrypt{Err: err}
+		}
+	}
+
+	dr.counter++
+	dr.final = final
+	dr.buf.Write(plainText)
+
+	return nil
+}
+
+func (dr *decryptingReader) Read(p []byte) (int, error) {
+	for dr.buf.Len() == 0 {
+		if dr.final {
+			return 0, io.EOF
+		}
+
+		err := dr.readChunk()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	return dr.buf.Read(p)
+}
@@ -0,0 +1,102 @@
+package common
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestEncryptingWriter(t *testing.T) {
+	key := RndBytes(32)
+
+	for _, size := range []int{0, 1, aeadStreamChunkSize, aeadStreamChunkSize + 1, 3*aeadStreamChunkSize + 17} {
+		plain := RndBytes(size)
+
+		encrypted := bytes.Buffer{}
+
+		ew, err := NewEncryptingWriterWith(&encrypted, CipherChaCha20Poly1305, KdfNone, key)
+		require.NoError(t, err)
+
+		_, err = io.Copy(ew, bytes.NewReader(plain))
+		require.NoError(t, err)
+		require.NoError(t, ew.Close())
+
+		ba := encrypted.Bytes()
+
+		dr, err := NewDecryptingReader(bytes.NewReader(ba), key)
+		require.NoError(t, err)
+
+		decrypted, err := io.ReadAll(dr)
+		require.NoError(t, err)
+		require.True(t, bytes.Equal(plain, decrypted))
+
+		// wrong key
+
+		dr, err = NewDecryptingReader(bytes.NewReader(ba), RndBytes(32))
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(dr)
+		require.ErrorAs(t, err, new(*ErrDecrypt))
+
+		// truncated stream
+
+		dr, err = NewDecryptingReader(bytes.NewReader(ba[:len(ba)-1]), key)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(dr)
+		require.ErrorAs(t, err, new(*ErrDecrypt))
+
+		// tampered stream
+
+		tampered := slices.Clone(ba)
+		tampered[len(tampered)-1] ^= 0xFF
+
+		dr, err = NewDecryptingReader(bytes.NewReader(tampered), key)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(dr)
+		require.ErrorAs(t, err, new(*ErrDecrypt))
+
+		// appended data
+
+		appended := append(slices.Clone(ba), 0)
+
+		dr, err = NewDecryptingReader(bytes.NewReader(appended), key)
+		require.NoError(t, err)
+
+		_, err = io.ReadAll(dr)
+		require.ErrorAs(t, err, new(*ErrDecrypt))
+	}
+}
+
+func TestEncryptingWriterSwapBuffer(t *testing.T) {
+	key := RndBytes(16)
+	plain := RndBytes(5 * aeadStreamChunkSize)
+
+	sb := NewSwapBuffer()
+	defer func() {
+		require.NoError(t, sb.Close())
+	}()
+
+	ew, err := NewEncryptingWriter(sb, key)
+	require.NoError(t, err)
+
+	_, err = ew.Write(plain)
+	require.NoError(t, err)
+	require.NoError(t, ew.Close())
+
+	filename := filepath.Join(t.TempDir(), "plain.bin")
+
+	dr, err := NewDecryptingReader(sb, key)
+	require.NoError(t, err)
+
+	require.NoError(t, FileStore(filename, dr))
+
+	decrypted, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.True(t, bytes.Equal(plain, decrypted))
+}
@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"github.com/kardianos/service"
 	"golang.org/x/mod/modfile"
+	"math"
 	"os"
 	"os/signal"
 	"path/filepath"
@@ -98,7 +99,7 @@ const (
 )
 
 var (
-	FlagService         = SystemFlagString(FlagNameService, "", "Service operation ("+strings.Join([]string{SERVICE_SIMULATE, SERVICE_START, SERVICE_STOP, SERVICE_RESTART, SERVICE_INSTALL, SERVICE_UNINSTALL}, ",")+")")
+	FlagService         = SystemFlagString(FlagNameService, "", "Service operation ("+strings.Join([]string{SERVICE_SIMULATE, SERVICE_START, SERVICE_STOP, SERVICE_RESTART, SERVICE_INSTALL, SERVICE_UNINSTALL}, ",")+")", ValidateEnum("", SERVICE_SIMULATE, SERVICE_START, SERVICE_STOP, SERVICE_RESTART, SERVICE_INSTALL, SERVICE_UNINSTALL))
 	FlagServiceUser     = SystemFlagString(FlagNameServiceUsername, "", "Service user")
 	FlagServicePassword = SystemFlagString(FlagNameServicePassword, "", "Service password")
 	FlagServiceTimeout  = SystemFlagInt(FlagNameServiceTimeout, 1000, "Service timeout")
@@ -211,8 +212,8 @@ func Init(title string, version string, git string, build string, description st
 	}
 
 	FlagAppProduct = SystemFlagString(FlagNameAppProduct, title, "app product")
-	FlagAppTicker = SystemFlagInt(FlagNameAppTicker, int(runTime.Milliseconds()), "app execution ticker")
-	FlagAppStartupDelay = SystemFlagInt(FlagNameAppStartupDelay, 0, "app startup delay")
+	FlagAppTicker = SystemFlagInt(FlagNameAppTicker, int(runTime.Milliseconds()), "app execution ticker", ValidateRange(0, math.MaxInt32))
+	FlagAppStartupDelay = SystemFlagInt(FlagNameAppStartupDelay, 0, "app startup delay", ValidateRange(0, math.MaxInt32))
 
 	app = &application{
 		Title:         title,
@@ -890,6 +891,10 @@ func done() {
 
 	onceShutdownHooks.Do(func() {
 		Events.Emit(EventShutdown{}, true)
+
+		// the buffered log entries including the ones of the shutdown listeners are written before the exit
+
+		Error(shutdownLog())
 	})
 }
 
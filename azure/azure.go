@@ -24,6 +24,8 @@ const (
 	FlagNameAzureTimeout      = "azure.timeout"
 	FlagNameAzureCfgConn      = "azure.cfg.conn"
 	FlagNameAzureCfgKey       = "azure.cfg.key"
+
+	AZKV_PREFIX = "azkv:"
 )
 
 var (
@@ -63,33 +65,86 @@ func init() {
 
 		ev.Flags = flags
 	})
-}
 
-type AzureAppCfg struct {
-	credentialClient azcore.TokenCredential
-	configClient     *azappconfig.Client
+	// flag values like "azkv:myvault/mysecret" or "azkv:https://myvault.vault.azure.net/mysecret"
+
+	common.RegisterSecretProvider(AZKV_PREFIX, common.SecretProviderFunc(func(reference string) (string, error) {
+		credentialClient, err := NewCredential(*FlagAzureTenantID, *FlagAzureClientID, *FlagAzureClientSecret)
+		if common.Error(err) {
+			return "", err
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), common.MillisecondToDuration(*FlagAzureTimeout))
+		defer func() {
+			cancel()
+		}()
+
+		return GetKeyVaultSecret(ctx, credentialClient, reference)
+	}))
 }
 
-func NewAzureAppCfg(tenantID string, clientID string, clientSecret string, cfgConn string) (*AzureAppCfg, error) {
+func NewCredential(tenantID string, clientID string, clientSecret string) (azcore.TokenCredential, error) {
 	common.DebugFunc()
 
-	var err error
-	var credentialClient azcore.TokenCredential
-
 	if tenantID != "" {
 		if clientID == "" || clientSecret == "" {
 			return nil, &common.ErrFlagNotDefined{Name: strings.Join([]string{FlagNameAzureTenantID, FlagNameAzureClientID, FlagNameAzureClientSecret}, ",")}
 		}
 
-		credentialClient, err = azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
-		if common.Error(err) {
-			return nil, err
-		}
-	} else {
-		credentialClient, err = azidentity.NewDefaultAzureCredential(nil)
+		credentialClient, err := azidentity.NewClientSecretCredential(tenantID, clientID, clientSecret, nil)
 		if common.Error(err) {
 			return nil, err
 		}
+
+		return credentialClient, nil
+	}
+
+	credentialClient, err := azidentity.NewDefaultAzureCredential(nil)
+	if common.Error(err) {
+		return nil, err
+	}
+
+	return credentialClient, nil
+}
+
+// GetKeyVaultSecret reads the secret by a reference "<vault name>/<secret name>" or "<vault URL>/<secret name>"
+func GetKeyVaultSecret(ctx context.Context, credentialClient azcore.TokenCredential, reference string) (string, error) {
+	p := strings.LastIndex(reference, "/")
+	if p == -1 {
+		return "", fmt.Errorf("invalid Azure key vault reference, expected <vault>/<secret>: %s", reference)
+	}
+
+	vault := reference[:p]
+	key := reference[p+1:]
+
+	if !strings.Contains(vault, "://") {
+		vault = fmt.Sprintf("https://%s.vault.azure.net", vault)
+	}
+
+	secretClient, err := azsecrets.NewClient(vault, credentialClient, nil)
+	if common.Error(err) {
+		return "", err
+	}
+
+	secretResp, err := secretClient.GetSecret(ctx, key, "", nil)
+	if common.Error(err) {
+		return "", err
+	}
+
+	return *secretResp.Value, nil
+}
+
+type AzureAppCfg struct {
+	credentialClient azcore.TokenCredential
+	configClient     *azappconfig.Client
+}
+
+func NewAzureAppCfg(tenantID string, clientID string, clientSecret string, cfgConn string) (*AzureAppCfg, error) {
+	common.DebugFunc()
+
+	credentialClient, err := NewCredential(tenantID, clientID, clientSecret)
+	if common.Error(err) {
+		return nil, err
 	}
 
 	var configClient *azappconfig.Client
@@ -195,17 +250,7 @@ func (azureAppCfg *AzureAppCfg) GetValue(ctx context.Context, key string) (strin
 		return "", err
 	}
 
-	secretClient, err := azsecrets.NewClient(secretUrl.String(), azureAppCfg.credentialClient, nil)
-	if common.Error(err) {
-		return "", err
-	}
-
-	secretResp, err := secretClient.GetSecret(ctx, key, "", nil)
-	if common.Error(err) {
-		return "", err
-	}
-
-	return *secretResp.Value, nil
+	return GetKeyVaultSecret(ctx, azureAppCfg.credentialClient, secretUrl.String()+"/"+key)
 }
 
 func (azureAppCfg *AzureAppCfg) SetValue(ctx context.Context, key string, value string) error {
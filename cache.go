@@ -1,15 +1,80 @@
 package common
 
 import (
+	"container/list"
 	"fmt"
+	"time"
 )
 
-// Cache is a thread-safe, LRU-based cache using a deque.
+type CacheEvictReason int
+
+const (
+	// CacheEvictCapacity is the eviction of the least recently used entry to free capacity
+	CacheEvictCapacity CacheEvictReason = iota
+	// CacheEvictExpired is the eviction of an entry after its TTL
+	CacheEvictExpired
+	// CacheEvictRemoved is the eviction by Remove or by a Put which replaces the value
+	CacheEvictRemoved
+)
+
+func (r CacheEvictReason) String() string {
+	switch r {
+	case CacheEvictCapacity:
+		return "capacity"
+	case CacheEvictExpired:
+		return "expired"
+	default:
+		return "removed"
+	}
+}
+
+// CacheOptions configures a Cache. Zero values are the defaults of NewCache.
+type CacheOptions[K comparable, V any] struct {
+	// Capacity is the maximum total weight of all entries, 0 is unlimited
+	Capacity int
+	// TTL is the default expiry of an entry, 0 does not expire
+	TTL time.Duration
+	// Sizer returns the weight of an entry, default weight is 1 so that the capacity is the count of entries
+	Sizer func(key K, value V) int
+	// OnEvict is called after an entry is evicted, outside of the lock of the cache
+	OnEvict func(key K, value V, reason CacheEvictReason)
+}
+
+// CacheStats are the counters of a Cache
+type CacheStats struct {
+	Hits        int64
+	Misses      int64
+	Evictions   int64
+	Expirations int64
+	Len         int
+	Weight      int
+}
+
+type cacheEntry[K comparable, V any] struct {
+	key     K
+	value   V
+	weight  int
+	expires time.Time
+}
+
+type cacheEviction[K comparable, V any] struct {
+	entry  *cacheEntry[K, V]
+	reason CacheEvictReason
+}
+
+// Cache is a thread-safe, LRU-based cache with optional TTL and weight based capacity.
+// Expired entries are removed lazily on access or by Purge.
 type Cache[K comparable, V any] struct {
 	capacity int
-	data     map[K]V
-	order    *Deque[K]
-	mu       ReentrantMutex // Ensure thread safety
+	ttl      time.Duration
+	sizer    func(key K, value V) int
+	onEvict  func(key K, value V, reason CacheEvictReason)
+	data     map[K]*list.Element
+	// order has the most recently used entry at the front
+	order  *list.List
+	weight int
+	stats  CacheStats
+	mu     ReentrantMutex // Ensure thread safety
 }
 
 type ErrNotFound[K comparable] struct {
@@ -20,12 +85,40 @@ func (e *ErrNotFound[K]) Error() string {
 	return fmt.Sprintf("Not found: %v", e.What)
 }
 
+type ErrCacheWeight[K comparable] struct {
+	What     K
+	Weight   int
+	Capacity int
+}
+
+func (e *ErrCacheWeight[K]) Error() string {
+	return fmt.Sprintf("weight %d of %v exceeds cache capacity %d", e.Weight, e.What, e.Capacity)
+}
+
 // NewCache creates a new cache with the specified capacity.
+// A capacity < 1 keeps only the latest entry, an unlimited cache is created by NewCacheWithOptions.
 func NewCache[K comparable, V any](capacity int) *Cache[K, V] {
+	return NewCacheWithOptions(CacheOptions[K, V]{
+		Capacity: Max(capacity, 1),
+	})
+}
+
+// NewCacheWithOptions creates a new cache with TTL, sizer and eviction callback.
+func NewCacheWithOptions[K comparable, V any](options CacheOptions[K, V]) *Cache[K, V] {
+	sizer := options.Sizer
+	if sizer == nil {
+		sizer = func(key K, value V) int {
+			return 1
+		}
+	}
+
 	return &Cache[K, V]{
-		capacity: capacity,
-		data:     make(map[K]V),
-		order:    NewDeque[K](),
+		capacity: options.Capacity,
+		ttl:      options.TTL,
+		sizer:    sizer,
+		onEvict:  options.OnEvict,
+		data:     make(map[K]*list.Element),
+		order:    list.New(),
 	}
 }
 
@@ -36,16 +129,107 @@ func (c *Cache[K, V]) Len() int {
 	return len(c.data)
 }
 
-// Put inserts a new value into the cache, or updates an existing one.
+// Stats returns the current counters
+func (c *Cache[K, V]) Stats() CacheStats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	stats := c.stats
+	stats.Len = len(c.data)
+	stats.Weight = c.weight
+
+	return stats
+}
+
+func (c *Cache[K, V]) expiry(ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+
+	return time.Now().Add(ttl)
+}
+
+func (e *cacheEntry[K, V]) isExpired(now time.Time) bool {
+	return !e.expires.IsZero() && !now.Before(e.expires)
+}
+
+func (c *Cache[K, V]) remove(elem *list.Element, reason CacheEvictReason, evicted []cacheEviction[K, V]) []cacheEviction[K, V] {
+	entry := c.order.Remove(elem).(*cacheEntry[K, V])
+
+	delete(c.data, entry.key)
+	c.weight -= entry.weight
+
+	switch reason {
+	case CacheEvictCapacity:
+		c.stats.Evictions++
+	case CacheEvictExpired:
+		c.stats.Expirations++
+	}
+
+	return append(evicted, cacheEviction[K, V]{entry: entry, reason: reason})
+}
+
+func (c *Cache[K, V]) notify(evicted []cacheEviction[K, V]) {
+	if c.onEvict == nil {
+		return
+	}
+
+	for _, e := range evicted {
+		c.onEvict(e.entry.key, e.entry.value, e.reason)
+	}
+}
+
+func (c *Cache[K, V]) put(key K, value V, ttl time.Duration) ([]cacheEviction[K, V], error) {
+	var evicted []cacheEviction[K, V]
+
+	weight := c.sizer(key, value)
+	if c.capacity > 0 && weight > c.capacity {
+		return nil, &ErrCacheWeight[K]{What: key, Weight: weight, Capacity: c.capacity}
+	}
+
+	elem, ok := c.data[key]
+	if ok {
+		evicted = c.remove(elem, CacheEvictRemoved, evicted)
+	}
+
+	// Remove least recently used items (from the back of the list)
+	for c.capacity > 0 && c.weight+weight > c.capacity {
+		evicted = c.remove(c.order.Back(), CacheEvictCapacity, evicted)
+	}
+
+	entry := &cacheEntry[K, V]{
+		key:     key,
+		value:   value,
+		weight:  weight,
+		expires: c.expiry(ttl),
+	}
+
+	c.data[key] = c.order.PushFront(entry)
+	c.weight += weight
+
+	return evicted, nil
+}
+
+// PutFunc inserts the value of fn if the key is not cached, otherwise the key is marked as recently used.
 func (c *Cache[K, V]) PutFunc(key K, fn func() (V, error)) error {
+	var evicted []cacheEviction[K, V]
+
+	defer func() {
+		c.notify(evicted)
+	}()
+
 	c.mu.Lock() // Lock for writing
 	defer c.mu.Unlock()
 
-	_, ok := c.data[key]
+	elem, ok := c.data[key]
 	if ok {
-		c.order.PushFront(key)
+		if !elem.Value.(*cacheEntry[K, V]).isExpired(time.Now()) {
+			c.order.MoveToFront(elem)
+
+			return nil
+		}
 
-		return nil
+		evicted = c.remove(elem, CacheEvictExpired, evicted)
 	}
 
 	value, err := fn()
@@ -53,93 +237,142 @@ func (c *Cache[K, V]) PutFunc(key K, fn func() (V, error)) error {
 		return err
 	}
 
-	if c.Len() >= c.capacity {
-		// Remove least recently used item (from the back of the deque)
-		leastUsedKey, _ := c.order.PopBack()
-		delete(c.data, leastUsedKey)
-	}
-
-	// Insert or update the value
-	c.data[key] = value
-	// Move the key to the front of the deque (mark it as most recently used)
-	c.order.PushFront(key)
+	e, err := c.put(key, value, c.ttl)
+	evicted = append(evicted, e...)
 
-	return nil
+	return err
 }
 
+// Put inserts a new value into the cache, or updates an existing one, with the default TTL.
 func (c *Cache[K, V]) Put(key K, value V) error {
-	return c.PutFunc(key, func() (V, error) {
-		return value, nil
-	})
+	return c.PutWithTTL(key, value, c.ttl)
+}
+
+// PutWithTTL inserts a new value into the cache, or updates an existing one, which expires after ttl. A ttl of 0 does not expire.
+func (c *Cache[K, V]) PutWithTTL(key K, value V, ttl time.Duration) error {
+	var evicted []cacheEviction[K, V]
+
+	defer func() {
+		c.notify(evicted)
+	}()
+
+	c.mu.Lock() // Lock for writing
+	defer c.mu.Unlock()
+
+	var err error
+
+	evicted, err = c.put(key, value, ttl)
+
+	return err
 }
 
 // Get retrieves a value from the cache and moves the key to the front (mark as recently used).
 func (c *Cache[K, V]) Get(key K) (V, error) {
+	var evicted []cacheEviction[K, V]
+
+	defer func() {
+		c.notify(evicted)
+	}()
+
 	c.mu.Lock() // Lock for writing (we move the key to the front, so it's a write operation)
 	defer c.mu.Unlock()
 
-	value, found := c.data[key]
+	elem, found := c.data[key]
+	if found && elem.Value.(*cacheEntry[K, V]).isExpired(time.Now()) {
+		evicted = c.remove(elem, CacheEvictExpired, evicted)
+
+		found = false
+	}
+
 	if !found {
+		c.stats.Misses++
+
 		var zero V
 		return zero, &ErrNotFound[K]{What: key}
 	}
 
-	// Move the key to the front of the deque (mark it as recently used)
-	c.order.PushFront(key)
-	return value, nil
+	c.stats.Hits++
+
+	// Move the key to the front (mark it as recently used)
+	c.order.MoveToFront(elem)
+	return elem.Value.(*cacheEntry[K, V]).value, nil
 }
 
 // Remove removes a key-value pair from the cache.
 func (c *Cache[K, V]) Remove(key K) error {
+	var evicted []cacheEviction[K, V]
+
+	defer func() {
+		c.notify(evicted)
+	}()
+
 	c.mu.Lock() // Lock for writing
 	defer c.mu.Unlock()
 
-	_, found := c.data[key]
+	elem, found := c.data[key]
 	if !found {
 		return &ErrNotFound[K]{What: key}
 	}
 
-	// Remove from the deque and data
-	_, err := c.order.PopFront() // or PopBack() depending on where the key is
-	if Error(err) {
-		return err
-	}
-	delete(c.data, key)
+	evicted = c.remove(elem, CacheEvictRemoved, evicted)
+
 	return nil
 }
 
+// Purge removes all expired entries
+func (c *Cache[K, V]) Purge() {
+	var evicted []cacheEviction[K, V]
+
+	defer func() {
+		c.notify(evicted)
+	}()
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	for elem := c.order.Back(); elem != nil; {
+		prev := elem.Prev()
+
+		if elem.Value.(*cacheEntry[K, V]).isExpired(now) {
+			evicted = c.remove(elem, CacheEvictExpired, evicted)
+		}
+
+		elem = prev
+	}
+}
+
 func (c *Cache[K, V]) KeyIndex(key K) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	i := 0
-	c.order.Iterate(func(current K) bool {
-		if key == current {
-			return false
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		if elem.Value.(*cacheEntry[K, V]).key == key {
+			return i
 		}
 
 		i++
-
-		return true
-	})
-
-	if i == len(c.data) {
-		i = -1
 	}
 
-	return i
+	return -1
 }
 
 func (c *Cache[K, V]) KeyAt(index int) K {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	var found K
 	i := 0
-	c.order.Iterate(func(current K) bool {
-		found = current
+	for elem := c.order.Front(); elem != nil; elem = elem.Next() {
+		found = elem.Value.(*cacheEntry[K, V]).key
 		if i == index {
-			return false
+			break
 		}
 
 		i++
-
-		return true
-	})
+	}
 
 	return found
 }
@@ -0,0 +1,383 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// CacheLoader loads the value of a key on a cache miss
+type CacheLoader[K comparable, V any] func(key K) (V, error)
+
+// CacheStore is the second tier of a LoadingCache which keeps the entries evicted from memory
+type CacheStore[K comparable, V any] interface {
+	// Load returns the value and the time it was loaded by the CacheLoader
+	Load(key K) (value V, loaded time.Time, found bool, err error)
+	Store(key K, value V, loaded time.Time) error
+	Delete(key K) error
+}
+
+// LoadingCacheOptions configures a LoadingCache
+type LoadingCacheOptions[K comparable, V any] struct {
+	CacheOptions[K, V]
+	// RefreshAfter reloads an entry asynchronously on access if it was loaded before, 0 does not refresh
+	RefreshAfter time.Duration
+	// Store is the optional second tier for entries evicted by capacity
+	Store CacheStore[K, V]
+}
+
+type loadedValue[V any] struct {
+	value  V
+	loaded time.Time
+}
+
+// cacheCall is a running load which is shared by all concurrent callers of the same key
+type cacheCall[V any] struct {
+	wg    sync.WaitGroup
+	value *loadedValue[V]
+	err   error
+}
+
+// LoadingCache is a read-through cache. Concurrent misses of the same key are loaded only once and the loader
+// runs without holding the lock of the cache.
+type LoadingCache[K comparable, V any] struct {
+	cache        *Cache[K, *loadedValue[V]]
+	loader       CacheLoader[K, V]
+	ttl          time.Duration
+	refreshAfter time.Duration
+	store        CacheStore[K, V]
+	mu           sync.Mutex
+	calls        map[K]*cacheCall[V]
+	refreshing   map[K]struct{}
+}
+
+func NewLoadingCache[K comparable, V any](loader CacheLoader[K, V], options LoadingCacheOptions[K, V]) *LoadingCache[K, V] {
+	lc := &LoadingCache[K, V]{
+		loader:       loader,
+		ttl:          options.TTL,
+		refreshAfter: options.RefreshAfter,
+		store:        options.Store,
+		calls:        make(map[K]*cacheCall[V]),
+		refreshing:   make(map[K]struct{}),
+	}
+
+	cacheOptions := CacheOptions[K, *loadedValue[V]]{
+		Capacity: options.Capacity,
+		TTL:      options.TTL,
+		OnEvict: func(key K, lv *loadedValue[V], reason CacheEvictReason) {
+			// cold entries are spilled to the second tier
+
+			if reason == CacheEvictCapacity && lc.store != nil {
+				DebugError(lc.store.Store(key, lv.value, lv.loaded))
+			}
+
+			if options.OnEvict != nil {
+				options.OnEvict(key, lv.value, reason)
+			}
+		},
+	}
+
+	if options.Sizer != nil {
+		cacheOptions.Sizer = func(key K, lv *loadedValue[V]) int {
+			return options.Sizer(key, lv.value)
+		}
+	}
+
+	lc.cache = NewCacheWithOptions(cacheOptions)
+
+	return lc
+}
+
+// Get returns the cached value or loads it from the second tier or by the loader
+func (lc *LoadingCache[K, V]) Get(key K) (V, error) {
+	lv, err := lc.cache.Get(key)
+	if err == nil {
+		if lc.refreshAfter > 0 && time.Since(lv.loaded) >= lc.refreshAfter {
+			lc.refresh(key)
+		}
+
+		return lv.value, nil
+	}
+
+	lv, err = lc.load(key, true)
+	if err != nil {
+		var zero V
+
+		return zero, err
+	}
+
+	return lv.value, nil
+}
+
+// load runs only once for concurrent callers of the same key
+func (lc *LoadingCache[K, V]) load(key K, useStore bool) (*loadedValue[V], error) {
+	lc.mu.Lock()
+
+	call, ok := lc.calls[key]
+	if ok {
+		lc.mu.Unlock()
+
+		call.wg.Wait()
+
+		return call.value, call.err
+	}
+
+	call = &cacheCall[V]{}
+	call.wg.Add(1)
+	lc.calls[key] = call
+
+	lc.mu.Unlock()
+
+	defer func() {
+		lc.mu.Lock()
+		delete(lc.calls, key)
+		lc.mu.Unlock()
+
+		call.wg.Done()
+	}()
+
+	call.value, call.err = lc.loadValue(key, useStore)
+
+	return call.value, call.err
+}
+
+func (lc *LoadingCache[K, V]) loadValue(key K, useStore bool) (*loadedValue[V], error) {
+	if useStore && lc.store != nil {
+		value, loaded, found, err := lc.store.Load(key)
+		if DebugError(err) {
+			found = false
+		}
+
+		if found {
+			age := time.Since(loaded)
+
+			if lc.ttl == 0 || age < lc.ttl {
+				lv := &loadedValue[V]{value: value, loaded: loaded}
+
+				ttl := time.Duration(0)
+				if lc.ttl > 0 {
+					ttl = lc.ttl - age
+				}
+
+				err := lc.cache.PutWithTTL(key, lv, ttl)
+				if err != nil {
+					return nil, err
+				}
+
+				return lv, nil
+			}
+
+			DebugError(lc.store.Delete(key))
+		}
+	}
+
+	value, err := lc.callLoader(key)
+	if err != nil {
+		return nil, err
+	}
+
+	lv := &loadedValue[V]{value: value, loaded: time.Now()}
+
+	err = lc.cache.Put(key, lv)
+	if err != nil {
+		return nil, err
+	}
+
+	return lv, nil
+}
+
+// callLoader returns a panic of the loader as error, so waiting callers and the refresh goroutine do not fail
+func (lc *LoadingCache[K, V]) callLoader(key K) (value V, err error) {
+	defer RecoverPanic(&err)
+
+	return lc.loader(key)
+}
+
+// refresh reloads the key asynchronously, the current value is returned until the refresh is done
+func (lc *LoadingCache[K, V]) refresh(key K) {
+	lc.mu.Lock()
+	defer lc.mu.Unlock()
+
+	_, ok := lc.refreshing[key]
+	if ok {
+		return
+	}
+
+	lc.refreshing[key] = struct{}{}
+
+	go func() {
+		defer func() {
+			lc.mu.Lock()
+			delete(lc.refreshing, key)
+			lc.mu.Unlock()
+		}()
+
+		// on failure the current value is kept until it expires
+
+		_, err := lc.load(key, false)
+		DebugError(err)
+	}()
+}
+
+// Invalidate removes the key from memory and from the second tier
+func (lc *LoadingCache[K, V]) Invalidate(key K) error {
+	err := lc.cache.Remove(key)
+	if _, ok := err.(*ErrNotFound[K]); ok {
+		err = nil
+	}
+
+	if lc.store != nil {
+		if e := lc.store.Delete(key); err == nil {
+			err = e
+		}
+	}
+
+	return err
+}
+
+// Stats returns the counters of the memory tier
+func (lc *LoadingCache[K, V]) Stats() CacheStats {
+	return lc.cache.Stats()
+}
+
+// Close spills all entries in memory to the second tier so that they survive a restart
+func (lc *LoadingCache[K, V]) Close() error {
+	if lc.store == nil {
+		return nil
+	}
+
+	lc.cache.mu.Lock()
+
+	entries := make([]*cacheEntry[K, *loadedValue[V]], 0, lc.cache.order.Len())
+	for elem := lc.cache.order.Front(); elem != nil; elem = elem.Next() {
+		entries = append(entries, elem.Value.(*cacheEntry[K, *loadedValue[V]]))
+	}
+
+	lc.cache.mu.Unlock()
+
+	for _, entry := range entries {
+		err := lc.store.Store(entry.key, entry.value.value, entry.value.loaded)
+		if Error(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// FileCacheStore is a CacheStore with one JSON file per key in a directory
+type FileCacheStore[K comparable, V any] struct {
+	dir string
+}
+
+type fileCacheRecord[K comparable, V any] struct {
+	Key    K         `json:"key"`
+	Value  V         `json:"value"`
+	Loaded time.Time `json:"loaded"`
+}
+
+func NewFileCacheStore[K comparable, V any](dir string) (*FileCacheStore[K, V], error) {
+	err := os.MkdirAll(dir, DefaultDirMode)
+	if Error(err) {
+		return nil, err
+	}
+
+	return &FileCacheStore[K, V]{
+		dir: dir,
+	}, nil
+}
+
+func (store *FileCacheStore[K, V]) path(key K) (string, error) {
+	ba, err := json.Marshal(key)
+	if err != nil {
+		return "", err
+	}
+
+	hash := sha256.Sum256(ba)
+
+	return filepath.Join(store.dir, hex.EncodeToString(hash[:])+".json"), nil
+}
+
+func (store *FileCacheStore[K, V]) Load(key K) (V, time.Time, bool, error) {
+	var zero V
+
+	path, err := store.path(key)
+	if err != nil {
+		return zero, time.Time{}, false, err
+	}
+
+	ba, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return zero, time.Time{}, false, nil
+	}
+	if err != nil {
+		return zero, time.Time{}, false, err
+	}
+
+	record := fileCacheRecord[K, V]{}
+
+	err = json.Unmarshal(ba, &record)
+	if err != nil {
+		return zero, time.Time{}, false, err
+	}
+
+	return record.Value, record.Loaded, true, nil
+}
+
+func (store *FileCacheStore[K, V]) Store(key K, value V, loaded time.Time) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	ba, err := json.Marshal(fileCacheRecord[K, V]{
+		Key:    key,
+		Value:  value,
+		Loaded: loaded,
+	})
+	if err != nil {
+		return err
+	}
+
+	// readers never see a partially written file
+
+	f, err := os.CreateTemp(store.dir, "*.tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(ba)
+
+	closeErr := f.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err == nil {
+		err = os.Rename(f.Name(), path)
+	}
+
+	if err != nil {
+		DebugError(os.Remove(f.Name()))
+	}
+
+	return err
+}
+
+func (store *FileCacheStore[K, V]) Delete(key K) error {
+	path, err := store.path(key)
+	if err != nil {
+		return err
+	}
+
+	err = os.Remove(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+
+	return err
+}
@@ -1,8 +1,12 @@
 package common
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 )
 
 func TestCache(t *testing.T) {
@@ -44,6 +48,14 @@ func TestCache(t *testing.T) {
 	// expect that 99 is not in cache
 	_, err = cache.Get(99)
 	require.Error(t, err)
+
+	// expect that a cache without capacity keeps the latest value
+	cache = NewCache[int, int](0)
+
+	require.NoError(t, cache.Put(1, 1))
+	require.NoError(t, cache.Put(2, 2))
+	require.Equal(t, 1, cache.Len())
+	require.Equal(t, 2, cache.KeyAt(0))
 }
 
 func TestCachePerformance(t *testing.T) {
@@ -57,3 +69,278 @@ func TestCachePerformance(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+
+func TestCacheRePut(t *testing.T) {
+	cache := NewCache[int, int](3)
+
+	for range 5 {
+		require.NoError(t, cache.Put(1, 1))
+	}
+	require.NoError(t, cache.Put(2, 2))
+	require.NoError(t, cache.Put(1, 11))
+
+	require.Equal(t, 2, cache.Len())
+	require.Equal(t, 1, cache.KeyAt(0))
+	require.Equal(t, 2, cache.KeyAt(1))
+	require.Equal(t, -1, cache.KeyIndex(3))
+
+	v, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, 11, v)
+
+	// Remove must remove the given key
+
+	require.NoError(t, cache.Remove(2))
+	require.Equal(t, 0, cache.KeyIndex(1))
+	require.Equal(t, -1, cache.KeyIndex(2))
+}
+
+func TestCacheTTL(t *testing.T) {
+	var evicted []string
+
+	cache := NewCacheWithOptions(CacheOptions[string, int]{
+		Capacity: 10,
+		TTL:      50 * time.Millisecond,
+		OnEvict: func(key string, value int, reason CacheEvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", key, reason))
+		},
+	})
+
+	require.NoError(t, cache.Put("default", 1))
+	require.NoError(t, cache.PutWithTTL("forever", 2, 0))
+	require.NoError(t, cache.PutWithTTL("purge", 3, time.Millisecond))
+
+	_, err := cache.Get("default")
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+
+	cache.Purge()
+	require.Equal(t, []string{"purge:expired"}, evicted)
+
+	time.Sleep(50 * time.Millisecond)
+
+	_, err = cache.Get("default")
+	var errNotFound *ErrNotFound[string]
+	require.ErrorAs(t, err, &errNotFound)
+
+	v, err := cache.Get("forever")
+	require.NoError(t, err)
+	require.Equal(t, 2, v)
+
+	require.Equal(t, []string{"purge:expired", "default:expired"}, evicted)
+	require.Equal(t, CacheStats{
+		Hits:        2,
+		Misses:      1,
+		Expirations: 2,
+		Len:         1,
+		Weight:      1,
+	}, cache.Stats())
+}
+
+func TestCacheWeight(t *testing.T) {
+	var evicted []string
+
+	cache := NewCacheWithOptions(CacheOptions[string, string]{
+		Capacity: 10,
+		Sizer: func(key string, value string) int {
+			return len(value)
+		},
+		OnEvict: func(key string, value string, reason CacheEvictReason) {
+			evicted = append(evicted, fmt.Sprintf("%s:%s", key, reason))
+		},
+	})
+
+	require.NoError(t, cache.Put("a", "1234"))
+	require.NoError(t, cache.Put("b", "1234"))
+	_, err := cache.Get("a")
+	require.NoError(t, err)
+
+	// "b" is the least recently used
+
+	require.NoError(t, cache.Put("c", "12345"))
+	require.Equal(t, []string{"b:capacity"}, evicted)
+
+	var errWeight *ErrCacheWeight[string]
+	require.ErrorAs(t, cache.Put("d", "12345678901"), &errWeight)
+
+	require.NoError(t, cache.Put("a", "1"))
+	require.Equal(t, []string{"b:capacity", "a:removed"}, evicted)
+
+	stats := cache.Stats()
+	require.Equal(t, int64(1), stats.Evictions)
+	require.Equal(t, 2, stats.Len)
+	require.Equal(t, 6, stats.Weight)
+}
+
+func TestLoadingCache(t *testing.T) {
+	var loads atomic.Int32
+
+	loader := func(key int) (string, error) {
+		loads.Add(1)
+
+		time.Sleep(50 * time.Millisecond)
+
+		if key < 0 {
+			return "", fmt.Errorf("invalid key: %d", key)
+		}
+
+		return fmt.Sprintf("value %d-%d", key, loads.Load()), nil
+	}
+
+	cache := NewLoadingCache(loader, LoadingCacheOptions[int, string]{
+		CacheOptions: CacheOptions[int, string]{
+			Capacity: 10,
+		},
+		RefreshAfter: 100 * time.Millisecond,
+	})
+
+	// concurrent misses are loaded only once
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			v, err := cache.Get(1)
+			require.NoError(t, err)
+			require.Equal(t, "value 1-1", v)
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, int32(1), loads.Load())
+
+	_, err := cache.Get(-1)
+	require.Error(t, err)
+
+	// the stale value is returned while it is refreshed
+
+	time.Sleep(100 * time.Millisecond)
+
+	v, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, "value 1-1", v)
+
+	require.Eventually(t, func() bool {
+		v, err := cache.Get(1)
+
+		return err == nil && v == "value 1-3"
+	}, time.Second, 10*time.Millisecond)
+}
+
+func TestLoadingCachePanic(t *testing.T) {
+	var panics atomic.Bool
+
+	loader := func(key int) (int, error) {
+		if panics.Load() {
+			panic("loader panic")
+		}
+
+		time.Sleep(50 * time.Millisecond)
+
+		return key * 10, nil
+	}
+
+	cache := NewLoadingCache(loader, LoadingCacheOptions[int, int]{
+		CacheOptions: CacheOptions[int, int]{
+			Capacity: 10,
+		},
+		RefreshAfter: 50 * time.Millisecond,
+	})
+
+	v, err := cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, 10, v)
+
+	// a panic of the loader is returned to all waiting callers
+
+	panics.Store(true)
+
+	wg := sync.WaitGroup{}
+	for range 10 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			_, err := cache.Get(2)
+
+			var errPanic *ErrPanic
+			require.ErrorAs(t, err, &errPanic)
+		}()
+	}
+	wg.Wait()
+
+	// a panic of the asynchronous refresh keeps the current value
+
+	time.Sleep(50 * time.Millisecond)
+
+	for range 5 {
+		v, err = cache.Get(1)
+		require.NoError(t, err)
+		require.Equal(t, 10, v)
+
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestLoadingCacheStore(t *testing.T) {
+	dir := t.TempDir()
+
+	var loads atomic.Int32
+
+	loader := func(key int) (int, error) {
+		loads.Add(1)
+
+		return key * 10, nil
+	}
+
+	newCache := func() *LoadingCache[int, int] {
+		store, err := NewFileCacheStore[int, int](dir)
+		require.NoError(t, err)
+
+		return NewLoadingCache(loader, LoadingCacheOptions[int, int]{
+			CacheOptions: CacheOptions[int, int]{
+				Capacity: 2,
+				TTL:      time.Hour,
+			},
+			Store: store,
+		})
+	}
+
+	cache := newCache()
+
+	for i := range 3 {
+		v, err := cache.Get(i)
+		require.NoError(t, err)
+		require.Equal(t, i*10, v)
+	}
+
+	// 0 was spilled to the store
+
+	v, err := cache.Get(0)
+	require.NoError(t, err)
+	require.Equal(t, 0, v)
+	require.Equal(t, int32(3), loads.Load())
+
+	require.NoError(t, cache.Close())
+
+	// all entries survive a restart
+
+	cache = newCache()
+
+	for i := range 3 {
+		v, err := cache.Get(i)
+		require.NoError(t, err)
+		require.Equal(t, i*10, v)
+	}
+
+	require.Equal(t, int32(3), loads.Load())
+
+	require.NoError(t, cache.Invalidate(1))
+
+	_, err = cache.Get(1)
+	require.NoError(t, err)
+	require.Equal(t, int32(4), loads.Load())
+}
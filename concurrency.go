@@ -5,7 +5,9 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/pkg/errors"
+	"math"
 	"runtime"
+	"runtime/debug"
 	"sort"
 	"strconv"
 	"strings"
@@ -20,8 +22,8 @@ var (
 )
 
 var (
-	FlagConcurrentLimit   = SystemFlagInt(FlagNameConcurrentLimit, Max(4, runtime.NumCPU()*2), "Limit of maximum current running tasks")
-	FLagConcurrentTimeout = SystemFlagInt(FlagNameConcurrentTimeout, 10000, "Tinmeout waiting for running a current running tasks")
+	FlagConcurrentLimit   = SystemFlagInt(FlagNameConcurrentLimit, Max(4, runtime.NumCPU()*2), "Limit of maximum current running tasks", ValidateRange(0, math.MaxInt32))
+	FLagConcurrentTimeout = SystemFlagInt(FlagNameConcurrentTimeout, 10000, "Tinmeout waiting for running a current running tasks", ValidateRange(0, math.MaxInt32))
 
 	registeredGoRoutines      = make(map[int]RuntimeInfo)
 	registeredGoRoutinesMutex = sync.Mutex{}
@@ -30,6 +32,56 @@ var (
 	concurrentLimitCh   chan struct{}
 )
 
+// ErrPanic is a recovered panic with the runtime info of the panicking code
+type ErrPanic struct {
+	Value       any
+	RuntimeInfo RuntimeInfo
+}
+
+func (e *ErrPanic) Error() string {
+	return fmt.Sprintf("panic: %v [%s]", e.Value, e.RuntimeInfo.String())
+}
+
+func (e *ErrPanic) Unwrap() error {
+	err, _ := e.Value.(error)
+
+	return err
+}
+
+// RecoverPanic converts a panic to an ErrPanic, it must be deferred directly: defer RecoverPanic(&err)
+func RecoverPanic(err *error) {
+	r := recover()
+	if r == nil {
+		return
+	}
+
+	ri := RuntimeInfo{UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, 0, time.Now()}
+
+	// the panicking func is the first caller outside of the runtime
+
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(2, pcs)])
+
+	for {
+		frame, more := frames.Next()
+
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			ri = newRuntimeInfo(frame.Function, frame.File, frame.Line, string(debug.Stack()))
+
+			break
+		}
+
+		if !more {
+			break
+		}
+	}
+
+	*err = &ErrPanic{
+		Value:       r,
+		RuntimeInfo: ri,
+	}
+}
+
 func RegisterConcurrentLimit() bool {
 	if *FlagConcurrentLimit == 0 {
 		return false
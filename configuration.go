@@ -5,11 +5,14 @@ import (
 	"flag"
 	"fmt"
 	"github.com/pkg/errors"
+	"math"
 	"os"
 	"path/filepath"
 	"reflect"
 	"slices"
 	"strings"
+	"sync"
+	"time"
 )
 
 type EventConfigurationReset struct {
@@ -20,15 +23,34 @@ type EventExternalFlags struct {
 	Flags map[string]string
 }
 
+type FlagChange struct {
+	Old    string
+	New    string
+	Origin string
+	Secret bool
+}
+
+type EventConfigurationChanged struct {
+	Changes map[string]FlagChange
+}
+
 type Configuration struct {
 	ApplicationTitle   string    `json:"applicationTitle"`
 	ApplicationVersion string    `json:"applicationVersion"`
 	Flags              KeyValues `json:"flags"`
 }
 
-type flagInfo struct {
-	Value  string
-	Origin string
+type FlagSource struct {
+	Value  string `json:"value"`
+	Origin string `json:"origin"`
+	Secret bool   `json:"secret"`
+}
+
+type FlagInfo struct {
+	Value      string       `json:"value"`
+	Origin     string       `json:"origin"`
+	Secret     bool         `json:"secret"`
+	Overridden []FlagSource `json:"overridden"`
 }
 
 var (
@@ -38,6 +60,11 @@ var (
 	FlagCfgFile       *string
 	FlagCfgIniFile    *string
 	FlagCfgIniSection *string
+	FlagCfgWatch      *int
+	FlagCfgExplain    *string
+	FlagCfgVaultFile  *string
+	FlagCfgRotate     *string
+	FlagCfgRotateOld  *string
 
 	CmdlineOnlyFlags = []string{
 		FlagNameService,
@@ -51,9 +78,17 @@ var (
 		FlagNameUsageMd,
 		FlagNameCfgIniFile,
 		FlagNameCfgIniSection,
+		FlagNameCfgWatch,
+		FlagNameCfgExplain,
+		FlagNameCfgVaultFile,
+		FlagNameCfgRotate,
+		FlagNameCfgRotateOld,
 	}
 
-	flagInfos = make(map[string]flagInfo)
+	flagInfos       = make(map[string]FlagInfo)
+	flagInfosMutex  sync.Mutex
+	reloadableFlags = make(map[string]sync.Locker)
+	cfgWatcher      *BackgroundTask
 )
 
 const (
@@ -63,6 +98,17 @@ const (
 	FlagNameCfgCreate     = "cfg.create"
 	FlagNameCfgIniFile    = "cfg.ini.file"
 	FlagNameCfgIniSection = "cfg.ini.section"
+	FlagNameCfgWatch      = "cfg.watch"
+	FlagNameCfgExplain    = "cfg.explain"
+	FlagNameCfgVaultFile  = "cfg.vault.file"
+	FlagNameCfgRotate     = "cfg.rotate"
+	FlagNameCfgRotateOld  = "cfg.rotate.oldkey"
+)
+
+const (
+	ExplainTable    = "table"
+	ExplainMarkdown = "markdown"
+	ExplainJson     = "json"
 )
 
 type ErrUnknownFlag struct {
@@ -94,12 +140,23 @@ func init() {
 			dir = wd
 		}
 
-		FlagCfgFile = SystemFlagString(FlagNameCfgFile, CleanPath(filepath.Join(dir, AppFilename(".json"))), "JSON file configuration path")
+		FlagCfgFile = SystemFlagString(FlagNameCfgFile, CleanPath(filepath.Join(dir, AppFilename(".json"))), "Configuration file path (JSON, YAML or TOML by file extension)")
 		FlagCfgExternal = SystemFlagString(FlagNameCfgExternal, "", "Configuration JSON content")
 		FlagCfgReset = SystemFlagBool(FlagNameCfgReset, false, "Reset configuration file")
 		FlagCfgCreate = SystemFlagBool(FlagNameCfgCreate, false, "Reset configuration file and exit")
 		FlagCfgIniFile = SystemFlagString(FlagNameCfgIniFile, CleanPath(filepath.Join(dir, AppFilename(".ini"))), "INI file configuration path")
 		FlagCfgIniSection = SystemFlagString(FlagNameCfgIniSection, DEFAULT_SECTION, "INI file section")
+		FlagCfgVaultFile = SystemFlagString(FlagNameCfgVaultFile, CleanPath(filepath.Join(dir, AppFilename(".vault"))), "Secret vault file path")
+		FlagCfgExplain = SystemFlagString(FlagNameCfgExplain, "", "Show the effective flag values and their origin and exit ("+strings.Join([]string{ExplainTable, ExplainMarkdown, ExplainJson}, ",")+")", ValidateEnum("", ExplainTable, ExplainMarkdown, ExplainJson))
+		FlagCfgRotate = SystemFlagString(FlagNameCfgRotate, "", "Re-encrypt all secret values of the cfg, INI and vault file with this new secret key and exit (e.g. file:newkey.txt)")
+		FlagCfgRotateOld = SystemFlagString(FlagNameCfgRotateOld, "", "Old secret key for re-encryption (default: current secret key)")
+		FlagCfgWatch = SystemFlagInt(FlagNameCfgWatch, 0, "Watch interval in msec to reload changed cfg and INI files (0 = disabled)", ValidateRange(0, math.MaxInt32))
+	})
+
+	Events.AddListener(EventShutdown{}, func(ev Event) {
+		if cfgWatcher != nil {
+			cfgWatcher.Stop(true)
+		}
 	})
 }
 
@@ -178,6 +235,17 @@ func IsCmdlineOnlyFlag(flagName string) bool {
 func initConfiguration() error {
 	DebugFunc()
 
+	if *FlagCfgRotate != "" {
+		count, err := RotateConfigurationSecrets(*FlagCfgRotateOld, *FlagCfgRotate)
+		if Error(err) {
+			return err
+		}
+
+		fmt.Printf("Secret values re-encrypted: %d\n", count)
+
+		return &ErrExit{}
+	}
+
 	*FlagCfgReset = *FlagCfgReset || !FileExists(*FlagCfgFile)
 
 	if *FlagCfgReset || *FlagCfgCreate {
@@ -204,6 +272,19 @@ func initConfiguration() error {
 		return err
 	}
 
+	if *FlagCfgExplain != "" {
+		err := explainFlags(*FlagCfgExplain)
+		if Error(err) {
+			return err
+		}
+
+		return &ErrExit{}
+	}
+
+	if *FlagCfgWatch > 0 {
+		startConfigurationWatcher()
+	}
+
 	return nil
 }
 
@@ -256,9 +337,13 @@ func LoadConfigurationFile[T any]() (*T, error) {
 
 	var content string
 
+	format := JsonConfigurationFormat
+
 	if FileExists(*FlagCfgFile) {
 		DebugFunc("read cfg from file: %s", *FlagCfgFile)
 
+		format = ConfigurationFormatOf(*FlagCfgFile)
+
 		ba, err := os.ReadFile(*FlagCfgFile)
 		if Error(err) {
 			return nil, err
@@ -270,7 +355,7 @@ func LoadConfigurationFile[T any]() (*T, error) {
 
 		// configuration set the to flag cfg.file=<content of configuration file>
 
-		if fl.Value.String() != fl.DefValue {
+		if fl != nil && fl.Value.String() != fl.DefValue {
 			content = fl.Value.String()
 
 			if content != "" {
@@ -287,7 +372,7 @@ func LoadConfigurationFile[T any]() (*T, error) {
 		}
 	}
 
-	ba, err := RemoveJsonComments([]byte(content))
+	ba, err := format.ToJson([]byte(content))
 	if Error(err) {
 		return nil, err
 	}
@@ -330,6 +415,13 @@ func SaveConfigurationFile(cfg any) error {
 		return err
 	}
 
+	format := ConfigurationFormatOf(*FlagCfgFile)
+
+	ba, err = format.FromJson(ba)
+	if Error(err) {
+		return err
+	}
+
 	err = os.WriteFile(*FlagCfgFile, ba, DefaultFileMode)
 	if Error(err) {
 		return err
@@ -341,6 +433,9 @@ func SaveConfigurationFile(cfg any) error {
 func setFlags() error {
 	DebugFunc()
 
+	flagInfosMutex.Lock()
+	defer flagInfosMutex.Unlock()
+
 	if len(flagInfos) != 0 {
 		Debug("set cached flag values")
 
@@ -442,40 +537,35 @@ func setFlags() error {
 		}
 
 		for key, value := range flagMaps[i].flags {
-			switch {
-			case IsEncrypted(value):
-				var err error
+			isSecret := IsSecretReference(value)
 
-				value, err = Secret(value)
-				if Error(err) {
-					return err
-				}
-			case strings.HasPrefix(value, "env:"):
-				envName := value[4:]
-				envValue, ok := os.LookupEnv(envName)
-				if !ok {
-					return fmt.Errorf("ENV variable cannot be evaluated: %s", value)
-				}
-
-				value = envValue
+			value, err := resolveFlagValue(value)
+			if Error(err) {
+				return err
 			}
 
 			if key == FlagNameCfgExternal {
 				value = externalCfg
 			}
 
-			err := flag.Set(key, value)
+			err = flag.Set(key, value)
 			if Error(err) {
-				return errors.Wrap(err, fmt.Sprintf("[%s] %s: %s\n", flagMaps[i].origin, key, value))
+				return errors.Wrap(err, fmt.Sprintf("[%s] %s: %s\n", flagMaps[i].origin, key, maskFlagValue(key, value, isSecret)))
 			}
 
-			flagInfos[key] = flagInfo{
+			flagInfos[key] = overrideFlagInfo(key, flagInfos[key], FlagInfo{
 				Value:  value,
 				Origin: flagMaps[i].origin,
-			}
+				Secret: isSecret,
+			})
 		}
 	}
 
+	err = validateFlagValues(flagInfos)
+	if Error(err) {
+		return err
+	}
+
 	Events.Emit(EventFlags{}, false)
 
 	debugFlags()
@@ -483,6 +573,357 @@ func setFlags() error {
 	return nil
 }
 
+func resolveFlagValue(value string) (string, error) {
+	return ResolveSecret(value)
+}
+
+// RegisterReloadableFlags allows ReloadConfiguration to change the flags. The values are changed while holding
+// the locker, so all readers of the flags must hold it too. Changes of other flags are applied only by a restart.
+func RegisterReloadableFlags(locker sync.Locker, names ...string) {
+	flagInfosMutex.Lock()
+	defer flagInfosMutex.Unlock()
+
+	for _, name := range names {
+		reloadableFlags[name] = locker
+	}
+}
+
+// ReloadConfiguration re-reads the cfg and INI file and applies all changed values of the reloadable flags.
+// Flags defined by ENV or command line keep their precedence, cmdline only flags are never changed.
+// The values are validated first, so either all changes are applied or none.
+// If any flag has been changed the EventConfigurationChanged is emitted.
+func ReloadConfiguration() (map[string]FlagChange, error) {
+	DebugFunc()
+
+	changes, restart, err := reloadFlags()
+	if Error(err) {
+		return nil, err
+	}
+
+	if len(restart) > 0 {
+		Warn("Configuration changed, restart required for flags: %s", strings.Join(restart, ", "))
+	}
+
+	if len(changes) == 0 {
+		return changes, nil
+	}
+
+	st := NewStringTable()
+	st.AddCols("Flag", "Old value", "New value", "Origin")
+
+	for _, name := range SortedKeys(changes) {
+		change := changes[name]
+
+		st.AddCols(name, maskFlagValue(name, change.Old, change.Secret), maskFlagValue(name, change.New, change.Secret), change.Origin)
+	}
+
+	Info("Configuration changed\n%s", st.Table())
+
+	Events.Emit(EventConfigurationChanged{Changes: changes}, false)
+
+	return changes, nil
+}
+
+// reloadFlags returns the applied changes of the reloadable flags and the changed flags which need a restart
+func reloadFlags() (map[string]FlagChange, []string, error) {
+	flagInfosMutex.Lock()
+	defer flagInfosMutex.Unlock()
+
+	cfgFlags, err := registerCfgFileFlags()
+	if Error(err) {
+		return nil, nil, err
+	}
+
+	iniFlags, err := registerIniFileFlags()
+	if Error(err) {
+		return nil, nil, err
+	}
+
+	var flagErr error
+	var restart []string
+	reloaded := make(map[string]FlagInfo)
+
+	flag.VisitAll(func(f *flag.Flag) {
+		if flagErr != nil || IsCmdlineOnlyFlag(f.Name) || f.Name == FlagNameCfgExternal {
+			return
+		}
+
+		info, ok := flagInfos[f.Name]
+		if !ok {
+			return
+		}
+
+		var value string
+		var origin string
+
+		switch {
+		case info.Origin == "env" || info.Origin == "args":
+			return
+		case iniFlags[f.Name] != "":
+			value = iniFlags[f.Name]
+			origin = "ini file"
+		case cfgFlags[f.Name] != "":
+			value = cfgFlags[f.Name]
+			origin = "cfg file"
+		case info.Origin == "external":
+			return
+		default:
+			value = f.DefValue
+			origin = "default"
+		}
+
+		isSecret := IsSecretReference(value)
+
+		value, flagErr = resolveFlagValue(value)
+		if flagErr != nil {
+			return
+		}
+
+		if value == info.Value {
+			return
+		}
+
+		if _, ok := reloadableFlags[f.Name]; !ok {
+			restart = append(restart, f.Name)
+
+			return
+		}
+
+		reloaded[f.Name] = FlagInfo{
+			Value:  value,
+			Origin: origin,
+			Secret: isSecret,
+		}
+	})
+
+	if Error(flagErr) {
+		return nil, nil, flagErr
+	}
+
+	err = validateFlagValues(reloaded)
+	if Error(err) {
+		return nil, nil, err
+	}
+
+	err = setReloadedFlags(reloaded)
+	if Error(err) {
+		return nil, nil, err
+	}
+
+	changes := make(map[string]FlagChange)
+
+	for name, info := range reloaded {
+		old := flagInfos[name]
+
+		changes[name] = FlagChange{
+			Old:    old.Value,
+			New:    info.Value,
+			Origin: info.Origin,
+			Secret: old.Secret || info.Secret,
+		}
+
+		info.Overridden = slices.DeleteFunc(slices.Clone(old.Overridden), func(source FlagSource) bool {
+			return source.Origin == info.Origin
+		})
+
+		flagInfos[name] = info
+	}
+
+	return changes, restart, nil
+}
+
+// setReloadedFlags sets the values while holding the lockers of the flags, on any error the old values are restored.
+// Nothing must be logged here since the log flags are synchronized by the log mutex.
+func setReloadedFlags(reloaded map[string]FlagInfo) error {
+	var lockers []sync.Locker
+
+	for _, name := range SortedKeys(reloaded) {
+		locker := reloadableFlags[name]
+		if !slices.Contains(lockers, locker) {
+			lockers = append(lockers, locker)
+		}
+	}
+
+	for _, locker := range lockers {
+		locker.Lock()
+	}
+
+	defer func() {
+		for _, locker := range slices.Backward(lockers) {
+			locker.Unlock()
+		}
+	}()
+
+	olds := make(map[string]string)
+
+	// flag.Set would write the map of the provided flags which is read concurrently by flag.Visit
+
+	for _, name := range SortedKeys(reloaded) {
+		f := flag.Lookup(name)
+		old := f.Value.String()
+
+		err := f.Value.Set(reloaded[name].Value)
+		if err != nil {
+			for name, old := range olds {
+				_ = flag.Lookup(name).Value.Set(old)
+			}
+
+			return errors.Wrap(err, fmt.Sprintf("[%s] %s: %s\n", reloaded[name].Origin, name, maskFlagValue(name, reloaded[name].Value, reloaded[name].Secret)))
+		}
+
+		olds[name] = old
+	}
+
+	return nil
+}
+
+func configurationFilesDate() time.Time {
+	latest := time.Time{}
+
+	for _, filename := range []string{*FlagCfgFile, *FlagCfgIniFile} {
+		if !FileExists(filename) {
+			continue
+		}
+
+		t, err := FileDate(filename)
+		if err != nil {
+			continue
+		}
+
+		if t.After(latest) {
+			latest = t
+		}
+	}
+
+	return latest
+}
+
+func startConfigurationWatcher() {
+	if cfgWatcher != nil && cfgWatcher.IsAlive() {
+		return
+	}
+
+	DebugFunc("interval: %v", MillisecondToDuration(*FlagCfgWatch))
+
+	cfgWatcher = NewBackgroundTask(func(task *BackgroundTask) {
+		defer UnregisterGoRoutine(RegisterGoRoutine(1))
+
+		ticker := time.NewTicker(MillisecondToDuration(*FlagCfgWatch))
+		defer ticker.Stop()
+
+		lastDate := configurationFilesDate()
+
+		for {
+			select {
+			case <-task.Channel():
+				return
+			case <-ticker.C:
+				date := configurationFilesDate()
+				if date.Equal(lastDate) {
+					continue
+				}
+
+				lastDate = date
+
+				_, err := ReloadConfiguration()
+				Error(err)
+			}
+		}
+	})
+
+	cfgWatcher.Start()
+}
+
+// overrideFlagInfo returns the new flag info which remembers all sources whose value has been overridden
+func overrideFlagInfo(name string, old FlagInfo, new FlagInfo) FlagInfo {
+	// the default flag values are taken after parsing the cmdline, so remember the real default value
+
+	if old.Origin == "default" {
+		fl := flag.Lookup(name)
+		if fl != nil {
+			old.Value = fl.DefValue
+		}
+	}
+
+	new.Overridden = slices.DeleteFunc(slices.Clone(old.Overridden), func(source FlagSource) bool {
+		return source.Origin == new.Origin
+	})
+
+	if old.Origin != "" && old.Origin != new.Origin && old.Value != new.Value {
+		new.Overridden = append(new.Overridden, FlagSource{
+			Value:  old.Value,
+			Origin: old.Origin,
+			Secret: old.Secret,
+		})
+	}
+
+	return new
+}
+
+// FlagOrigins returns the effective value, the winning origin and the overridden sources of all flags
+func FlagOrigins() map[string]FlagInfo {
+	flagInfosMutex.Lock()
+	defer flagInfosMutex.Unlock()
+
+	m := make(map[string]FlagInfo)
+
+	for name, info := range flagInfos {
+		info.Overridden = slices.Clone(info.Overridden)
+
+		m[name] = info
+	}
+
+	return m
+}
+
+func maskFlagValue(name string, value string, secret bool) string {
+	if secret && value != "" {
+		return strings.Repeat("X", 5) + "..."
+	}
+
+	if name == FlagNameCfgExternal {
+		value = CapString(value, 80)
+	}
+
+	return HideSecretFlags(name, value)
+}
+
+// FlagOriginsTable returns the flag provenance as table with masked secret values
+func FlagOriginsTable() *StringTable {
+	origins := FlagOrigins()
+
+	st := NewStringTable()
+	st.AddCols("Flag", "Value", "Origin", "Overridden")
+
+	for _, name := range SortedKeys(origins) {
+		info := origins[name]
+
+		overridden := make([]string, 0, len(info.Overridden))
+		for _, source := range info.Overridden {
+			overridden = append(overridden, fmt.Sprintf("%s=%s", source.Origin, maskFlagValue(name, source.Value, source.Secret)))
+		}
+
+		st.AddCols(name, maskFlagValue(name, info.Value, info.Secret), info.Origin, strings.Join(overridden, ", "))
+	}
+
+	return st
+}
+
+func explainFlags(format string) error {
+	st := FlagOriginsTable()
+
+	switch format {
+	case ExplainTable:
+		return st.TableToWriter(os.Stdout)
+	case ExplainMarkdown:
+		return st.MarkdownToWriter(os.Stdout)
+	case ExplainJson:
+		return st.JSONToWriter(os.Stdout)
+	default:
+		return fmt.Errorf("unknown explain format: %s", format)
+	}
+}
+
 func debugFlags() {
 	st := NewStringTable()
 	st.AddCols("Flag", "ENV name", "Value", "Origin", "Only cmdline")
@@ -496,11 +937,7 @@ func debugFlags() {
 			flagOnlyCmdline = "*"
 		}
 
-		value := HideSecretFlags(f.Name, flagValue.Value)
-
-		if f.Name == FlagNameCfgExternal {
-			value = CapString(value, 80)
-		}
+		value := maskFlagValue(f.Name, flagValue.Value, flagValue.Secret)
 
 		st.AddCols(f.Name, FlagNameAsEnvName(f.Name), value, flagOrigin, flagOnlyCmdline)
 	})
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ConfigurationFormat converts a configuration file format from and to JSON.
+// The JSON representation is the common ground so that all configuration structs only need JSON tags.
+type ConfigurationFormat struct {
+	Name     string
+	ToJson   func(ba []byte) ([]byte, error)
+	FromJson func(ba []byte) ([]byte, error)
+}
+
+var (
+	configurationFormats      = make(map[string]*ConfigurationFormat)
+	configurationFormatsMutex sync.Mutex
+
+	JsonConfigurationFormat = &ConfigurationFormat{
+		Name: "JSON",
+		ToJson: func(ba []byte) ([]byte, error) {
+			return RemoveJsonComments(ba)
+		},
+		FromJson: func(ba []byte) ([]byte, error) {
+			return ba, nil
+		},
+	}
+
+	YamlConfigurationFormat = &ConfigurationFormat{
+		Name: "YAML",
+		ToJson: func(ba []byte) ([]byte, error) {
+			m := make(map[string]any)
+
+			err := yaml.Unmarshal(ba, &m)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(m)
+		},
+		FromJson: func(ba []byte) ([]byte, error) {
+			m, err := jsonToMap(ba)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := bytes.Buffer{}
+
+			encoder := yaml.NewEncoder(&buf)
+			encoder.SetIndent(2)
+
+			err = encoder.Encode(m)
+			if err != nil {
+				return nil, err
+			}
+
+			return buf.Bytes(), nil
+		},
+	}
+
+	TomlConfigurationFormat = &ConfigurationFormat{
+		Name: "TOML",
+		ToJson: func(ba []byte) ([]byte, error) {
+			m := make(map[string]any)
+
+			err := toml.Unmarshal(ba, &m)
+			if err != nil {
+				return nil, err
+			}
+
+			return json.Marshal(m)
+		},
+		FromJson: func(ba []byte) ([]byte, error) {
+			m, err := jsonToMap(ba)
+			if err != nil {
+				return nil, err
+			}
+
+			buf := bytes.Buffer{}
+
+			err = toml.NewEncoder(&buf).Encode(m)
+			if err != nil {
+				return nil, err
+			}
+
+			return buf.Bytes(), nil
+		},
+	}
+)
+
+func init() {
+	RegisterConfigurationFormat(".json", JsonConfigurationFormat)
+	RegisterConfigurationFormat(".yaml", YamlConfigurationFormat)
+	RegisterConfigurationFormat(".yml", YamlConfigurationFormat)
+	RegisterConfigurationFormat(".toml", TomlConfigurationFormat)
+}
+
+// RegisterConfigurationFormat registers a configuration format for the file extension (e.g. ".yaml")
+func RegisterConfigurationFormat(ext string, format *ConfigurationFormat) {
+	configurationFormatsMutex.Lock()
+	defer configurationFormatsMutex.Unlock()
+
+	configurationFormats[strings.ToLower(ext)] = format
+}
+
+// ConfigurationFormatOf returns the configuration format by the file extension of the filename.
+// Unknown extensions fall back to JSON, which was the only format before.
+func ConfigurationFormatOf(filename string) *ConfigurationFormat {
+	configurationFormatsMutex.Lock()
+	defer configurationFormatsMutex.Unlock()
+
+	ext := strings.ToLower(filepath.Ext(filename))
+
+	format, ok := configurationFormats[ext]
+	if !ok {
+		return JsonConfigurationFormat
+	}
+
+	return format
+}
+
+func jsonToMap(ba []byte) (map[string]any, error) {
+	m := make(map[string]any)
+
+	err := json.Unmarshal(ba, &m)
+	if err != nil {
+		return nil, err
+	}
+
+	// nil values cannot be represented in all formats
+
+	for k, v := range m {
+		if v == nil {
+			delete(m, k)
+		}
+	}
+
+	return m, nil
+}
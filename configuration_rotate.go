@@ -0,0 +1,288 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// RotateSecret re-encrypts a "secret:" value from the old key to the new key.
+// An empty old key uses the default secret key lookup of Secret().
+func RotateSecret(value string, oldKey string, newKey string) (string, error) {
+	if !IsEncrypted(value) {
+		return value, nil
+	}
+
+	if newKey == "" {
+		return "", fmt.Errorf("new secret key is not defined")
+	}
+
+	var oldKeys []string
+	if oldKey != "" {
+		oldKeys = append(oldKeys, oldKey)
+	}
+
+	plain, err := Secret(value, oldKeys...)
+	if Error(err) {
+		return "", err
+	}
+
+	encrypted, err := Secret(plain, newKey)
+	if Error(err) {
+		return "", err
+	}
+
+	return encrypted, nil
+}
+
+// RotateConfigurationSecrets re-encrypts all secret values of the cfg file, the INI file and the vault file.
+// A backup of every modified file is written before. On any error all files are restored, so no file is left
+// encrypted with the new key while another one still uses the old key. Returns the count of re-encrypted values.
+func RotateConfigurationSecrets(oldKey string, newKey string) (int, error) {
+	DebugFunc()
+
+	oldKey, err := resolveRotationKey("old", oldKey)
+	if Error(err) {
+		return 0, err
+	}
+
+	newKey, err = resolveRotationKey("new", newKey)
+	if Error(err) {
+		return 0, err
+	}
+
+	filenames := []string{*FlagCfgFile, *FlagCfgIniFile}
+	if FlagCfgVaultFile != nil {
+		filenames = append(filenames, *FlagCfgVaultFile)
+	}
+
+	originals := make(map[string][]byte)
+
+	for _, filename := range filenames {
+		if !FileExists(filename) {
+			continue
+		}
+
+		ba, err := os.ReadFile(filename)
+		if Error(err) {
+			return 0, err
+		}
+
+		originals[filename] = ba
+	}
+
+	count := 0
+
+	for _, rotate := range []func(string, string) (int, error){rotateCfgFileSecrets, rotateIniFileSecrets, rotateVaultSecrets} {
+		n, err := rotate(oldKey, newKey)
+		if Error(err) {
+			restoreFiles(originals)
+
+			return 0, err
+		}
+
+		count += n
+	}
+
+	return count, nil
+}
+
+// restoreFiles writes back the original content of the already rotated files
+func restoreFiles(originals map[string][]byte) {
+	for _, filename := range SortedKeys(originals) {
+		ba, err := os.ReadFile(filename)
+		if err == nil && bytes.Equal(ba, originals[filename]) {
+			continue
+		}
+
+		Warn("Restore file after failed rotation: %s", filename)
+
+		Error(os.WriteFile(filename, originals[filename], DefaultFileMode))
+	}
+}
+
+// resolveRotationKey resolves a secret key reference. A key which is still a reference after resolving
+// is rejected, so a value is never re-encrypted with the reference string itself.
+func resolveRotationKey(name string, key string) (string, error) {
+	resolved, err := ResolveSecret(key)
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve %s secret key: %w", name, err)
+	}
+
+	if IsSecretReference(resolved) {
+		return "", fmt.Errorf("%s secret key is still a secret reference after resolving", name)
+	}
+
+	return resolved, nil
+}
+
+func rotateCfgFileSecrets(oldKey string, newKey string) (int, error) {
+	if !FileExists(*FlagCfgFile) {
+		return 0, nil
+	}
+
+	DebugFunc(*FlagCfgFile)
+
+	format := ConfigurationFormatOf(*FlagCfgFile)
+
+	raw, err := os.ReadFile(*FlagCfgFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	ba, err := format.ToJson(raw)
+	if Error(err) {
+		return 0, err
+	}
+
+	cfg := struct {
+		Flags []any `json:"flags"`
+	}{}
+
+	decoder := json.NewDecoder(bytes.NewReader(ba))
+	decoder.UseNumber()
+
+	err = decoder.Decode(&cfg)
+	if Error(err) {
+		return 0, err
+	}
+
+	// only the encrypted values are replaced in the raw file content, so formatting, comments,
+	// key order and any application specific content are kept
+
+	rotated := make(map[string]string)
+	count := 0
+
+	for _, item := range cfg.Flags {
+		s, ok := item.(string)
+		if !ok {
+			continue
+		}
+
+		kv := KeyValue(s)
+		if !IsEncrypted(kv.Value()) {
+			continue
+		}
+
+		count++
+
+		if _, ok := rotated[kv.Value()]; ok {
+			continue
+		}
+
+		if !bytes.Contains(raw, []byte(kv.Value())) {
+			return 0, fmt.Errorf("cannot find secret of flag %s in %s", kv.Key(), *FlagCfgFile)
+		}
+
+		value, err := RotateSecret(kv.Value(), oldKey, newKey)
+		if Error(err) {
+			return 0, fmt.Errorf("cannot rotate secret of flag %s: %w", kv.Key(), err)
+		}
+
+		rotated[kv.Value()] = value
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	for _, old := range SortedKeys(rotated) {
+		raw = bytes.ReplaceAll(raw, []byte(old), []byte(rotated[old]))
+	}
+
+	err = FileBackup(*FlagCfgFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	err = os.WriteFile(*FlagCfgFile, raw, DefaultFileMode)
+	if Error(err) {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func rotateIniFileSecrets(oldKey string, newKey string) (int, error) {
+	if !FileExists(*FlagCfgIniFile) {
+		return 0, nil
+	}
+
+	DebugFunc(*FlagCfgIniFile)
+
+	ini := NewIniFile()
+
+	err := ini.LoadFile(*FlagCfgIniFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	count := 0
+
+	for _, section := range ini.Sections() {
+		for _, key := range ini.Keys(section) {
+			value := ini.Get(key, section)
+			if !IsEncrypted(value) {
+				continue
+			}
+
+			value, err := RotateSecret(value, oldKey, newKey)
+			if Error(err) {
+				return 0, fmt.Errorf("cannot rotate secret of flag %s in section %s: %w", key, section, err)
+			}
+
+			ini.Set(key, value, section)
+			count++
+		}
+	}
+
+	if count == 0 {
+		return 0, nil
+	}
+
+	err = FileBackup(*FlagCfgIniFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	err = ini.SaveToFile(*FlagCfgIniFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	return count, nil
+}
+
+func rotateVaultSecrets(oldKey string, newKey string) (int, error) {
+	if FlagCfgVaultFile == nil || !FileExists(*FlagCfgVaultFile) {
+		return 0, nil
+	}
+
+	DebugFunc(*FlagCfgVaultFile)
+
+	vault, err := LoadSecretVault(*FlagCfgVaultFile)
+	if Error(err) {
+		return 0, err
+	}
+
+	for _, name := range vault.Names() {
+		value, err := RotateSecret(vault.Secrets[name], oldKey, newKey)
+		if Error(err) {
+			return 0, fmt.Errorf("cannot rotate secret %s of vault: %w", name, err)
+		}
+
+		vault.Secrets[name] = value
+	}
+
+	if len(vault.Secrets) == 0 {
+		return 0, nil
+	}
+
+	err = vault.Save()
+	if Error(err) {
+		return 0, err
+	}
+
+	return len(vault.Secrets), nil
+}
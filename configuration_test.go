@@ -0,0 +1,335 @@
+package common
+
+import (
+	"encoding/json"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+)
+
+var (
+	flagCfgTestValue   = flag.String("cfgtest.value", "default", "configuration test value")
+	flagCfgTestNumber  = flag.Int("cfgtest.number", 1, "configuration test number")
+	flagCfgTestVerbose = flag.Bool("cfgtest.verbose", false, "configuration test verbose")
+	flagCfgTestRestart = flag.String("cfgtest.restart", "default", "configuration test flag which needs a restart")
+)
+
+func init() {
+	RegisterFlagValidators("cfgtest.number", ValidateRange(1, 10))
+	RegisterReloadableFlags(&sync.Mutex{}, "cfgtest.value", "cfgtest.number", "cfgtest.verbose")
+}
+
+func withConfigurationFiles(t *testing.T) (string, string) {
+	dir := t.TempDir()
+
+	cfgFile := filepath.Join(dir, "cfgtest.json")
+	iniFile := filepath.Join(dir, "cfgtest.ini")
+	iniSection := DEFAULT_SECTION
+
+	oldCfgFile := FlagCfgFile
+	oldIniFile := FlagCfgIniFile
+	oldIniSection := FlagCfgIniSection
+	oldFlagInfos := flagInfos
+
+	FlagCfgFile = &cfgFile
+	FlagCfgIniFile = &iniFile
+	FlagCfgIniSection = &iniSection
+	flagInfos = make(map[string]FlagInfo)
+
+	t.Cleanup(func() {
+		FlagCfgFile = oldCfgFile
+		FlagCfgIniFile = oldIniFile
+		FlagCfgIniSection = oldIniSection
+		flagInfos = oldFlagInfos
+
+		require.NoError(t, flag.Set("cfgtest.value", "default"))
+		require.NoError(t, flag.Set("cfgtest.number", "1"))
+		require.NoError(t, flag.Set("cfgtest.verbose", "false"))
+	})
+
+	return cfgFile, iniFile
+}
+
+func TestReloadConfiguration(t *testing.T) {
+	cfgFile, iniFile := withConfigurationFiles(t)
+
+	flagInfos["cfgtest.value"] = FlagInfo{
+		Value:  "default",
+		Origin: "default",
+	}
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`{"flags":["cfgtest.value=cfg"]}`), DefaultFileMode))
+
+	changes, err := ReloadConfiguration()
+	require.NoError(t, err)
+	require.Equal(t, FlagChange{Old: "default", New: "cfg", Origin: "cfg file"}, changes["cfgtest.value"])
+	require.Equal(t, "cfg", *flagCfgTestValue)
+
+	// nothing changed, nothing reported
+
+	changes, err = ReloadConfiguration()
+	require.NoError(t, err)
+	require.Empty(t, changes)
+
+	// INI file has precedence over cfg file
+
+	require.NoError(t, os.WriteFile(iniFile, []byte("cfgtest.value=ini\n"), DefaultFileMode))
+
+	changes, err = ReloadConfiguration()
+	require.NoError(t, err)
+	require.Equal(t, FlagChange{Old: "cfg", New: "ini", Origin: "ini file"}, changes["cfgtest.value"])
+
+	// removed from all files falls back to the default value
+
+	require.NoError(t, FileDelete(iniFile))
+	require.NoError(t, FileDelete(cfgFile))
+
+	changes, err = ReloadConfiguration()
+	require.NoError(t, err)
+	require.Equal(t, FlagChange{Old: "ini", New: "default", Origin: "default"}, changes["cfgtest.value"])
+
+	// cmdline flags are never overwritten
+
+	flagInfos["cfgtest.value"] = FlagInfo{
+		Value:  "args",
+		Origin: "args",
+	}
+
+	require.NoError(t, os.WriteFile(iniFile, []byte("cfgtest.value=ini\n"), DefaultFileMode))
+
+	changes, err = ReloadConfiguration()
+	require.NoError(t, err)
+	require.Empty(t, changes)
+}
+
+func TestReloadConfigurationAllOrNone(t *testing.T) {
+	cfgFile, _ := withConfigurationFiles(t)
+
+	for _, name := range []string{"cfgtest.value", "cfgtest.number", "cfgtest.verbose", "cfgtest.restart"} {
+		flagInfos[name] = FlagInfo{
+			Value:  flag.Lookup(name).DefValue,
+			Origin: "default",
+		}
+	}
+
+	// flags which are not reloadable keep their value until a restart
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`{"flags":["cfgtest.value=cfg","cfgtest.restart=cfg"]}`), DefaultFileMode))
+
+	changes, err := ReloadConfiguration()
+	require.NoError(t, err)
+	require.Equal(t, []string{"cfgtest.value"}, SortedKeys(changes))
+	require.Equal(t, "cfg", *flagCfgTestValue)
+	require.Equal(t, "default", *flagCfgTestRestart)
+
+	// an invalid value rejects all changes
+
+	for _, flags := range []string{
+		`"cfgtest.value=other","cfgtest.number=99"`,
+		`"cfgtest.value=other","cfgtest.verbose=notabool"`,
+	} {
+		require.NoError(t, os.WriteFile(cfgFile, []byte(`{"flags":[`+flags+`]}`), DefaultFileMode))
+
+		_, err = ReloadConfiguration()
+		require.Error(t, err)
+		require.Equal(t, "cfg", *flagCfgTestValue)
+		require.Equal(t, 1, *flagCfgTestNumber)
+		require.False(t, *flagCfgTestVerbose)
+		require.Equal(t, "cfg", FlagOrigins()["cfgtest.value"].Value)
+	}
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte(`{"flags":["cfgtest.value=other","cfgtest.number=5","cfgtest.verbose=true"]}`), DefaultFileMode))
+
+	changes, err = ReloadConfiguration()
+	require.NoError(t, err)
+	require.Len(t, changes, 3)
+	require.Equal(t, "other", *flagCfgTestValue)
+	require.Equal(t, 5, *flagCfgTestNumber)
+	require.True(t, *flagCfgTestVerbose)
+}
+
+func TestConfigurationFormats(t *testing.T) {
+	contents := map[string]string{
+		"cfgtest.json": `{"flags":["cfgtest.value=json"]}`,
+		"cfgtest.yaml": "flags:\n  - cfgtest.value=yaml\n",
+		"cfgtest.yml":  "flags:\n  - cfgtest.value=yml\n",
+		"cfgtest.toml": "flags = [\"cfgtest.value=toml\"]\n",
+	}
+
+	for filename, content := range contents {
+		t.Run(filename, func(t *testing.T) {
+			cfgFile, _ := withConfigurationFiles(t)
+
+			cfgFile = filepath.Join(filepath.Dir(cfgFile), filename)
+			FlagCfgFile = &cfgFile
+
+			require.NoError(t, os.WriteFile(cfgFile, []byte(content), DefaultFileMode))
+
+			m, err := registerCfgFileFlags()
+			require.NoError(t, err)
+			require.Equal(t, FileNameExt(filename)[1:], m["cfgtest.value"])
+
+			format := ConfigurationFormatOf(cfgFile)
+
+			ba, err := format.FromJson([]byte(`{"applicationTitle":"test","flags":["cfgtest.value=roundtrip"]}`))
+			require.NoError(t, err)
+
+			ba, err = format.ToJson(ba)
+			require.NoError(t, err)
+
+			cfg := Configuration{}
+			require.NoError(t, json.Unmarshal(ba, &cfg))
+			require.Equal(t, "test", cfg.ApplicationTitle)
+			require.Equal(t, []string{"cfgtest.value"}, cfg.Flags.Keys())
+		})
+	}
+
+	cfgFile, _ := withConfigurationFiles(t)
+
+	cfgFile = filepath.Join(filepath.Dir(cfgFile), "cfgtest.yaml")
+	FlagCfgFile = &cfgFile
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte("flags:\n  - unknown.flag=1\n"), DefaultFileMode))
+
+	_, err := registerCfgFileFlags()
+	require.ErrorAs(t, err, new(*ErrUnknownFlag))
+
+	// unknown extensions are loaded as JSON
+
+	for _, filename := range []string{"cfgtest.cfg", "cfgtest"} {
+		cfgFile = filepath.Join(filepath.Dir(cfgFile), filename)
+		FlagCfgFile = &cfgFile
+
+		require.Equal(t, JsonConfigurationFormat, ConfigurationFormatOf(cfgFile))
+
+		require.NoError(t, os.WriteFile(cfgFile, []byte(`{"flags":["cfgtest.value=json"]}`), DefaultFileMode))
+
+		m, err := registerCfgFileFlags()
+		require.NoError(t, err)
+		require.Equal(t, "json", m["cfgtest.value"])
+	}
+}
+
+func TestFlagOrigins(t *testing.T) {
+	withConfigurationFiles(t)
+
+	info := FlagInfo{}
+	for _, source := range []FlagInfo{
+		{Value: "args", Origin: "default"},
+		{Value: "env", Origin: "env"},
+		{Value: "ini", Origin: "ini file"},
+		{Value: "env", Origin: "env"},
+		{Value: "args", Origin: "args", Secret: true},
+	} {
+		info = overrideFlagInfo("cfgtest.value", info, source)
+	}
+
+	flagInfos["cfgtest.value"] = info
+
+	origins := FlagOrigins()
+	require.Equal(t, "args", origins["cfgtest.value"].Value)
+	require.Equal(t, "args", origins["cfgtest.value"].Origin)
+	require.Equal(t, []FlagSource{
+		{Value: "default", Origin: "default"},
+		{Value: "ini", Origin: "ini file"},
+		{Value: "env", Origin: "env"},
+	}, origins["cfgtest.value"].Overridden)
+
+	st := FlagOriginsTable()
+	require.Equal(t, 2, st.Rows())
+	require.Equal(t, []string{"cfgtest.value", "XXXXX...", "args", "default=default, ini file=ini, env=env"}, st.Cells[1])
+}
+
+func TestRotateConfigurationSecrets(t *testing.T) {
+	cfgFile, iniFile := withConfigurationFiles(t)
+
+	oldKey := "0123456789abcdef"
+	newKey := "a new secret key of any length"
+
+	encrypted, err := Secret("cfg secret", oldKey)
+	require.NoError(t, err)
+
+	cfgContent := `{"custom":"kept","number":12345678901234567890,"flags":["cfgtest.value=` + encrypted + `","cfgtest.plain=plain"]}`
+
+	require.NoError(t, os.WriteFile(cfgFile, []byte(cfgContent), DefaultFileMode))
+	require.NoError(t, os.WriteFile(iniFile, []byte("[default]\ncfgtest.value="+encrypted+"\n"), DefaultFileMode))
+
+	// a new key which does not resolve or resolves to another reference is rejected
+
+	keyFile := filepath.Join(t.TempDir(), "key.txt")
+	require.NoError(t, os.WriteFile(keyFile, []byte("env:CFGTEST_KEY"), DefaultFileMode))
+
+	for _, key := range []string{FILE_PREFIX + "no-such-file", FILE_PREFIX + keyFile} {
+		_, err = RotateConfigurationSecrets(oldKey, key)
+		require.Error(t, err)
+		require.False(t, FileExists(cfgFile+".1"))
+	}
+
+	count, err := RotateConfigurationSecrets(oldKey, newKey)
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+
+	require.True(t, FileExists(cfgFile+".1"))
+	require.True(t, FileExists(iniFile+".1"))
+
+	cfg, err := LoadConfigurationFile[Configuration]()
+	require.NoError(t, err)
+
+	value, err := cfg.Flags.Get("cfgtest.value")
+	require.NoError(t, err)
+	require.NotEqual(t, encrypted, value)
+
+	plain, err := Secret(value, newKey)
+	require.NoError(t, err)
+	require.Equal(t, "cfg secret", plain)
+
+	plain, err = cfg.Flags.Get("cfgtest.plain")
+	require.NoError(t, err)
+	require.Equal(t, "plain", plain)
+
+	// only the secret is changed in the cfg file
+
+	ba, err := os.ReadFile(cfgFile)
+	require.NoError(t, err)
+	require.Equal(t, strings.ReplaceAll(cfgContent, encrypted, value), string(ba))
+
+	ini := NewIniFile()
+	require.NoError(t, ini.LoadFile(iniFile))
+
+	plain, err = Secret(ini.Get("cfgtest.value"), newKey)
+	require.NoError(t, err)
+	require.Equal(t, "cfg secret", plain)
+
+	// the old key cannot decrypt anymore
+
+	_, err = RotateConfigurationSecrets(oldKey, newKey)
+	require.Error(t, err)
+
+	// a failed rotation of the vault restores the already rotated cfg and INI file
+
+	rotatedCfg, err := os.ReadFile(cfgFile)
+	require.NoError(t, err)
+
+	otherEncrypted, err := Secret("other secret", "another key of any length")
+	require.NoError(t, err)
+
+	vaultFile := filepath.Join(t.TempDir(), "cfgtest.vault")
+	require.NoError(t, WriteJsonFile(vaultFile, map[string]string{"other": otherEncrypted}, DefaultFileMode))
+
+	oldVaultFile := FlagCfgVaultFile
+	FlagCfgVaultFile = &vaultFile
+	defer func() {
+		FlagCfgVaultFile = oldVaultFile
+	}()
+
+	_, err = RotateConfigurationSecrets(newKey, "a third secret key")
+	require.Error(t, err)
+
+	ba, err = os.ReadFile(cfgFile)
+	require.NoError(t, err)
+	require.Equal(t, rotatedCfg, ba)
+}
@@ -0,0 +1,307 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule returns the next activation time after t
+type Schedule interface {
+	Next(t time.Time) time.Time
+}
+
+type ErrCronSpec struct {
+	Spec string
+	Msg  string
+}
+
+func (e *ErrCronSpec) Error() string {
+	return fmt.Sprintf("invalid cron spec %q: %s", e.Spec, e.Msg)
+}
+
+// cronBits has a bit set for every matching value of a field
+type cronBits uint64
+
+func (b cronBits) has(v int) bool {
+	return b&(1<<uint(v)) != 0
+}
+
+type cronField struct {
+	name  string
+	min   int
+	max   int
+	names map[string]int
+}
+
+var (
+	cronSecond = cronField{"second", 0, 59, nil}
+	cronMinute = cronField{"minute", 0, 59, nil}
+	cronHour   = cronField{"hour", 0, 23, nil}
+	cronDom    = cronField{"day of month", 1, 31, nil}
+	cronMonth  = cronField{"month", 1, 12, map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// day of week 7 is sunday like 0
+	cronDow = cronField{"day of week", 0, 7, map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+
+	cronDescriptors = map[string]string{
+		"@yearly":   "0 0 0 1 1 *",
+		"@annually": "0 0 0 1 1 *",
+		"@monthly":  "0 0 0 1 * *",
+		"@weekly":   "0 0 0 * * 0",
+		"@daily":    "0 0 0 * * *",
+		"@midnight": "0 0 0 * * *",
+		"@hourly":   "0 0 * * * *",
+	}
+)
+
+// CronSchedule is a parsed cron expression
+type CronSchedule struct {
+	second cronBits
+	minute cronBits
+	hour   cronBits
+	dom    cronBits
+	month  cronBits
+	dow    cronBits
+	// day of month and day of week match either if both are restricted
+	domStar bool
+	dowStar bool
+	// loc is the time zone of the expression, nil is the current app timezone
+	loc *time.Location
+}
+
+// IntervalSchedule activates at fixed intervals aligned to the start of the day in its location.
+// Unlike AlignedTicker, which aligns to midnight UTC, "@every 6h" activates at 0, 6, 12 and 18 o'clock local time.
+type IntervalSchedule struct {
+	Interval time.Duration
+	// Loc is the time zone of the start of the day, nil is the current app timezone
+	Loc *time.Location
+}
+
+// ParseSchedule parses a cron expression with 5 fields (minute hour day-of-month month day-of-week) or 6 fields with
+// leading seconds, a descriptor like "@daily" or a fixed interval like "@every 15m".
+// An optional "TZ=<location>" prefix overrides loc. A nil loc is the timezone of the app (app.timezone) at each activation.
+func ParseSchedule(spec string, loc *time.Location) (Schedule, error) {
+	fields := strings.Fields(spec)
+	if len(fields) == 0 {
+		return nil, &ErrCronSpec{Spec: spec, Msg: "empty"}
+	}
+
+	if tz, ok := strings.CutPrefix(fields[0], "TZ="); ok {
+		var err error
+
+		loc, err = time.LoadLocation(tz)
+		if err != nil {
+			return nil, &ErrCronSpec{Spec: spec, Msg: err.Error()}
+		}
+
+		fields = fields[1:]
+	}
+
+	if len(fields) > 0 && strings.HasPrefix(fields[0], "@") {
+		if fields[0] == "@every" {
+			if len(fields) != 2 {
+				return nil, &ErrCronSpec{Spec: spec, Msg: "missing interval"}
+			}
+
+			interval, err := time.ParseDuration(fields[1])
+			if err != nil || interval < time.Second || interval > 24*time.Hour {
+				return nil, &ErrCronSpec{Spec: spec, Msg: "interval must be between 1s and 24h"}
+			}
+
+			return &IntervalSchedule{Interval: interval, Loc: loc}, nil
+		}
+
+		descriptor, ok := cronDescriptors[strings.ToLower(fields[0])]
+		if !ok || len(fields) != 1 {
+			return nil, &ErrCronSpec{Spec: spec, Msg: "unknown descriptor"}
+		}
+
+		fields = strings.Fields(descriptor)
+	}
+
+	switch len(fields) {
+	case 5:
+		fields = append([]string{"0"}, fields...)
+	case 6:
+	default:
+		return nil, &ErrCronSpec{Spec: spec, Msg: "expected 5 or 6 fields"}
+	}
+
+	schedule := &CronSchedule{
+		domStar: fields[3] == "*" || fields[3] == "?",
+		dowStar: fields[5] == "*" || fields[5] == "?",
+		loc:     loc,
+	}
+
+	var err error
+
+	for i, target := range []struct {
+		bits  *cronBits
+		field cronField
+	}{
+		{&schedule.second, cronSecond},
+		{&schedule.minute, cronMinute},
+		{&schedule.hour, cronHour},
+		{&schedule.dom, cronDom},
+		{&schedule.month, cronMonth},
+		{&schedule.dow, cronDow},
+	} {
+		*target.bits, err = parseCronField(fields[i], target.field)
+		if err != nil {
+			return nil, &ErrCronSpec{Spec: spec, Msg: err.Error()}
+		}
+	}
+
+	if schedule.dow.has(7) {
+		schedule.dow |= 1
+	}
+
+	return schedule, nil
+}
+
+func parseCronValue(s string, field cronField) (int, error) {
+	if v, ok := field.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+
+	v, err := strconv.Atoi(s)
+	if err != nil || v < field.min || v > field.max {
+		return 0, fmt.Errorf("invalid %s: %s", field.name, s)
+	}
+
+	return v, nil
+}
+
+// parseCronField parses a comma separated list of "*", "n", "n-m" each with an optional "/step"
+func parseCronField(s string, field cronField) (cronBits, error) {
+	var bits cronBits
+
+	for _, part := range strings.Split(s, ",") {
+		rng, stepStr, hasStep := strings.Cut(part, "/")
+
+		step := 1
+
+		if hasStep {
+			var err error
+
+			step, err = strconv.Atoi(stepStr)
+			if err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step of %s: %s", field.name, part)
+			}
+		}
+
+		from, to := field.min, field.max
+
+		if rng != "*" && rng != "?" {
+			fromStr, toStr, isRange := strings.Cut(rng, "-")
+
+			var err error
+
+			from, err = parseCronValue(fromStr, field)
+			if err != nil {
+				return 0, err
+			}
+
+			to = from
+
+			switch {
+			case isRange:
+				to, err = parseCronValue(toStr, field)
+				if err != nil {
+					return 0, err
+				}
+			case hasStep:
+				// "n/step" runs from n to the end of the range
+				to = field.max
+			}
+
+			if to < from {
+				return 0, fmt.Errorf("invalid range of %s: %s", field.name, part)
+			}
+		}
+
+		for v := from; v <= to; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+
+	return bits, nil
+}
+
+func (schedule *CronSchedule) dayMatches(t time.Time) bool {
+	domMatch := schedule.dom.has(t.Day())
+	dowMatch := schedule.dow.has(int(t.Weekday()))
+
+	if schedule.domStar || schedule.dowStar {
+		return domMatch && dowMatch
+	}
+
+	return domMatch || dowMatch
+}
+
+// Next returns the next matching time after t in the time zone of the schedule or the zero time if there is none within 5 years
+func (schedule *CronSchedule) Next(t time.Time) time.Time {
+	loc := schedule.loc
+	if loc == nil {
+		loc = time.Local
+	}
+
+	// the fields are compared by wall clock, so a time skipped by a DST change is not activated
+
+	t = t.In(loc).Truncate(time.Second).Add(time.Second)
+
+	yearLimit := t.Year() + 5
+
+	for t.Year() <= yearLimit {
+		y, m, d := t.Date()
+
+		switch {
+		case !schedule.month.has(int(m)):
+			t = time.Date(y, m+1, 1, 0, 0, 0, 0, loc)
+		case !schedule.dayMatches(t):
+			t = time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		case !schedule.hour.has(t.Hour()):
+			t = time.Date(y, m, d, t.Hour()+1, 0, 0, 0, loc)
+		case !schedule.minute.has(t.Minute()):
+			t = time.Date(y, m, d, t.Hour(), t.Minute()+1, 0, 0, loc)
+		case !schedule.second.has(t.Second()):
+			t = time.Date(y, m, d, t.Hour(), t.Minute(), t.Second()+1, 0, loc)
+		default:
+			return t
+		}
+	}
+
+	return time.Time{}
+}
+
+// Next returns the next multiple of the interval since the start of the day of t. The multiples are wall clock times,
+// so they are kept on DST changes, and they start again with every day.
+func (schedule *IntervalSchedule) Next(t time.Time) time.Time {
+	loc := schedule.Loc
+	if loc == nil {
+		loc = time.Local
+	}
+
+	t = t.In(loc)
+	y, m, d := t.Date()
+
+	elapsed := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second + time.Duration(t.Nanosecond())
+
+	for n := elapsed/schedule.Interval + 1; ; n++ {
+		offset := n * schedule.Interval
+		if offset >= 24*time.Hour {
+			return time.Date(y, m, d+1, 0, 0, 0, 0, loc)
+		}
+
+		next := time.Date(y, m, d, 0, 0, 0, int(offset), loc)
+		if next.After(t) {
+			return next
+		}
+	}
+}
@@ -0,0 +1,111 @@
+package common
+
+import (
+	"github.com/stretchr/testify/require"
+	"testing"
+	"time"
+)
+
+func TestParseSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	from := time.Date(2024, 3, 15, 10, 30, 15, 0, time.UTC)
+
+	tests := []struct {
+		spec    string
+		want    time.Time
+		wantErr bool
+	}{
+		{"* * * * *", time.Date(2024, 3, 15, 10, 31, 0, 0, time.UTC), false},
+		{"* * * * * *", time.Date(2024, 3, 15, 10, 30, 16, 0, time.UTC), false},
+		{"*/15 * * * *", time.Date(2024, 3, 15, 10, 45, 0, 0, time.UTC), false},
+		{"0 9-17/4 * * *", time.Date(2024, 3, 15, 13, 0, 0, 0, time.UTC), false},
+		{"0 0 * * mon,sat", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 * * 7", time.Date(2024, 3, 17, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 1 jan-feb *", time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 31 * *", time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), false},
+		{"0 0 30 2 *", time.Time{}, false},
+		// day of month or day of week if both are restricted
+		{"0 0 20 * fri", time.Date(2024, 3, 20, 0, 0, 0, 0, time.UTC), false},
+		{"@daily", time.Date(2024, 3, 16, 0, 0, 0, 0, time.UTC), false},
+		{"@hourly", time.Date(2024, 3, 15, 11, 0, 0, 0, time.UTC), false},
+		{"@every 20m", time.Date(2024, 3, 15, 10, 40, 0, 0, time.UTC), false},
+		{"@every 7h", time.Date(2024, 3, 15, 14, 0, 0, 0, time.UTC), false},
+		{"TZ=Europe/Berlin @every 6h", time.Date(2024, 3, 15, 12, 0, 0, 0, berlin), false},
+		{"TZ=Europe/Berlin 0 12 * * *", time.Date(2024, 3, 15, 12, 0, 0, 0, berlin), false},
+		{"", time.Time{}, true},
+		{"* * * *", time.Time{}, true},
+		{"60 * * * *", time.Time{}, true},
+		{"5-1 * * * *", time.Time{}, true},
+		{"*/0 * * * *", time.Time{}, true},
+		{"@never", time.Time{}, true},
+		{"@every 1ms", time.Time{}, true},
+		{"@every 25h", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.spec, func(t *testing.T) {
+			schedule, err := ParseSchedule(tt.spec, time.UTC)
+
+			if tt.wantErr {
+				var errSpec *ErrCronSpec
+				require.ErrorAs(t, err, &errSpec)
+
+				return
+			}
+
+			require.NoError(t, err)
+			require.True(t, tt.want.Equal(schedule.Next(from)), "want %v got %v", tt.want, schedule.Next(from))
+		})
+	}
+}
+
+func TestIntervalSchedule(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	schedule := &IntervalSchedule{Interval: 7 * time.Hour, Loc: berlin}
+
+	// the intervals start again with the next day
+
+	require.Equal(t, time.Date(2024, 3, 16, 0, 0, 0, 0, berlin), schedule.Next(time.Date(2024, 3, 15, 21, 0, 0, 0, berlin)))
+
+	// the wall clock times are kept on the change to summer time
+
+	schedule = &IntervalSchedule{Interval: 6 * time.Hour, Loc: berlin}
+
+	next := time.Date(2024, 3, 31, 0, 0, 0, 0, berlin)
+	for _, hour := range []int{6, 12, 18, 0} {
+		next = schedule.Next(next)
+		require.Equal(t, hour, next.Hour())
+	}
+}
+
+func TestCronScheduleDST(t *testing.T) {
+	berlin, err := time.LoadLocation("Europe/Berlin")
+	require.NoError(t, err)
+
+	// 2:30 does not exist on the day of the change to summer time
+
+	schedule, err := ParseSchedule("30 2 * * *", berlin)
+	require.NoError(t, err)
+
+	next := schedule.Next(time.Date(2024, 3, 30, 12, 0, 0, 0, berlin))
+	require.Equal(t, time.Date(2024, 4, 1, 2, 30, 0, 0, berlin), next)
+
+	// the time zone of the app is used without a location
+
+	orgLocal := time.Local
+	defer func() {
+		time.Local = orgLocal
+	}()
+
+	time.Local = berlin
+
+	schedule, err = ParseSchedule("0 12 * * *", nil)
+	require.NoError(t, err)
+
+	next = schedule.Next(time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC))
+	require.True(t, time.Date(2024, 7, 1, 10, 0, 0, 0, time.UTC).Equal(next))
+}
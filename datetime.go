@@ -49,7 +49,15 @@ func init() {
 	Events.AddListener(EventInit{}, func(ev Event) {
 		name, _ := time.Now().In(time.Local).Zone()
 
-		FlagTimezone = SystemFlagString(FlagNameAppTimezone, name, "app time zone")
+		FlagTimezone = SystemFlagString(FlagNameAppTimezone, name, "app time zone", func(value string) error {
+			if value == name {
+				return nil
+			}
+
+			_, err := time.LoadLocation(value)
+
+			return err
+		})
 	})
 
 	Events.AddListener(EventFlags{}, func(ev Event) {
@@ -75,6 +83,17 @@ func FileTimestamp(now ...time.Time) string {
 	return strings.ReplaceAll(n.Format(time.RFC3339), ":", "-")
 }
 
+// ParseFileTimestamp parses a timestamp created by FileTimestamp
+func ParseFileTimestamp(v string) (time.Time, error) {
+	// the colon of the zone offset is restored, "Z" is the UTC zone
+
+	if !strings.HasSuffix(v, "Z") && len(v) > 3 {
+		v = v[:len(v)-3] + ":" + v[len(v)-2:]
+	}
+
+	return time.Parse("2006-01-02T15-04-05Z07:00", v)
+}
+
 // ParseDateTime parses only date, but no time
 func ParseDateTime(mask string, v string) (time.Time, error) {
 	l, err := time.LoadLocation("Local")
@@ -126,6 +126,10 @@ func (networkConnection *NetworkConnection) SetWriteDeadline(t time.Time) error
 }
 
 type NetworkClient struct {
+	// RetryPolicy and CircuitBreaker are optional for Connect
+	RetryPolicy    *RetryPolicy
+	CircuitBreaker *CircuitBreaker
+
 	address   string
 	tlsConfig *tls.Config
 }
@@ -148,10 +152,26 @@ func (networkClient *NetworkClient) Stop() error {
 }
 
 func (networkClient *NetworkClient) Connect() (*NetworkConnection, error) {
+	return networkClient.ConnectContext(context.Background())
+}
+
+// ConnectContext connects like Connect, the retries and a running dial stop when ctx is done
+func (networkClient *NetworkClient) ConnectContext(ctx context.Context) (*NetworkConnection, error) {
+	return RetryWithBreaker(ctx, networkClient.RetryPolicy, networkClient.CircuitBreaker, func(ctx context.Context) (*NetworkConnection, error) {
+		return networkClient.connect(ctx)
+	})
+}
+
+func (networkClient *NetworkClient) connect(ctx context.Context) (*NetworkConnection, error) {
 	if networkClient.tlsConfig != nil {
 		Debug("Dial TLS connection: %s...", networkClient.address)
 
-		socket, err := tls.DialWithDialer(&net.Dialer{Deadline: CalcDeadline(time.Now(), MillisecondToDuration(*FlagIoConnectTimeout))}, "tcp", networkClient.address, networkClient.tlsConfig)
+		dialer := &tls.Dialer{
+			NetDialer: &net.Dialer{Deadline: CalcDeadline(time.Now(), MillisecondToDuration(*FlagIoConnectTimeout))},
+			Config:    networkClient.tlsConfig,
+		}
+
+		socket, err := dialer.DialContext(ctx, "tcp", networkClient.address)
 		if Error(err) {
 			return nil, err
 		}
@@ -162,7 +182,9 @@ func (networkClient *NetworkClient) Connect() (*NetworkConnection, error) {
 	} else {
 		Debug("Dial connection: %s...", networkClient.address)
 
-		socket, err := net.DialTimeout("tcp", networkClient.address, MillisecondToDuration(*FlagIoConnectTimeout))
+		dialer := &net.Dialer{Timeout: MillisecondToDuration(*FlagIoConnectTimeout)}
+
+		socket, err := dialer.DialContext(ctx, "tcp", networkClient.address)
 		if Error(err) {
 			return nil, err
 		}
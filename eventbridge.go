@@ -0,0 +1,421 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	eventBridgeQueueSize   = 1000
+	eventBridgeSeenSize    = 10000
+	eventBridgeMaxLineSize = 16 * 1024 * 1024
+	eventBridgeMaxDelay    = 30 * time.Second
+)
+
+// EventBridge relays the registered event types between processes as JSON lines over TCP/TLS.
+// Every bridge forwards received events to all its other connections, so the processes may be connected in any topology.
+// Loops are prevented by a unique message id which is relayed only once and by never sending an event back
+// which was received from the network.
+type EventBridge struct {
+	// ReconnectDelay is the initial delay before a client reconnects, it is doubled on every failure
+	ReconnectDelay time.Duration
+
+	eventManager  *EventManager
+	nodeId        string
+	mu            sync.Mutex
+	types         map[string]reflect.Type
+	subscriptions []*Subscription
+	conns         map[*eventBridgeConn]struct{}
+	servers       []*NetworkServer
+	seen          map[string]struct{}
+	seenRing      []string
+	seenIndex     int
+	relaying      map[uint64]reflect.Type
+	ctx           context.Context
+	cancel        context.CancelFunc
+	wg            sync.WaitGroup
+}
+
+type eventBridgeMessage struct {
+	Id     string          `json:"id"`
+	Origin string          `json:"origin"`
+	Type   string          `json:"type"`
+	Event  json.RawMessage `json:"event"`
+}
+
+type eventBridgeConn struct {
+	conn *NetworkConnection
+	out  chan []byte
+}
+
+type ErrEventBridgeType struct {
+	Name string
+}
+
+func (e *ErrEventBridgeType) Error() string {
+	return fmt.Sprintf("event type already registered: %s", e.Name)
+}
+
+func NewEventBridge(eventManager *EventManager) *EventBridge {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &EventBridge{
+		ReconnectDelay: time.Second,
+		eventManager:   eventManager,
+		nodeId:         uuid.New().String(),
+		types:          make(map[string]reflect.Type),
+		conns:          make(map[*eventBridgeConn]struct{}),
+		seen:           make(map[string]struct{}),
+		seenRing:       make([]string, eventBridgeSeenSize),
+		relaying:       make(map[uint64]reflect.Type),
+		ctx:            ctx,
+		cancel:         cancel,
+	}
+}
+
+// RegisterBridgeEvent relays the events of type T by the name, which must be the same in all processes
+func RegisterBridgeEvent[T any](bridge *EventBridge, name string) error {
+	DebugFunc(name)
+
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+
+	_, ok := bridge.types[name]
+	if ok {
+		return &ErrEventBridgeType{Name: name}
+	}
+
+	eventType := reflect.TypeFor[T]()
+
+	bridge.types[name] = eventType
+
+	bridge.subscriptions = append(bridge.subscriptions, Subscribe(bridge.eventManager, 0, func(event T) error {
+		// network errors are not errors of the emitter
+
+		DebugError(bridge.publish(eventType, name, event))
+
+		return nil
+	}))
+
+	return nil
+}
+
+// Listen accepts connections of other bridges
+func (bridge *EventBridge) Listen(address string, tlsConfig *tls.Config) error {
+	DebugFunc(address)
+
+	server, err := NewNetworkServer(address, tlsConfig)
+	if Error(err) {
+		return err
+	}
+
+	err = server.Start()
+	if Error(err) {
+		return err
+	}
+
+	bridge.mu.Lock()
+	bridge.servers = append(bridge.servers, server)
+	bridge.mu.Unlock()
+
+	bridge.wg.Add(1)
+	go func() {
+		defer bridge.wg.Done()
+
+		for bridge.ctx.Err() == nil {
+			conn, err := server.Connect()
+			if err != nil {
+				if bridge.ctx.Err() != nil || IsErrNetClosed(err) {
+					return
+				}
+
+				continue
+			}
+
+			bridge.wg.Add(1)
+			go func() {
+				defer bridge.wg.Done()
+
+				bridge.serve(conn)
+			}()
+		}
+	}()
+
+	return nil
+}
+
+// Dial connects to another bridge and reconnects until the bridge is closed
+func (bridge *EventBridge) Dial(address string, tlsConfig *tls.Config) error {
+	DebugFunc(address)
+
+	client, err := NewNetworkClient(address, tlsConfig)
+	if Error(err) {
+		return err
+	}
+
+	bridge.wg.Add(1)
+	go func() {
+		defer bridge.wg.Done()
+
+		delay := bridge.ReconnectDelay
+
+		for bridge.ctx.Err() == nil {
+			conn, err := client.Connect()
+			if err == nil {
+				delay = bridge.ReconnectDelay
+
+				bridge.serve(conn)
+			}
+
+			select {
+			case <-bridge.ctx.Done():
+				return
+			case <-time.After(delay):
+			}
+
+			if err != nil {
+				delay = Min(delay*2, eventBridgeMaxDelay)
+			}
+		}
+	}()
+
+	return nil
+}
+
+// Close stops all servers and connections and unsubscribes all registered event types
+func (bridge *EventBridge) Close() error {
+	DebugFunc()
+
+	bridge.cancel()
+
+	bridge.mu.Lock()
+
+	var err error
+
+	for _, server := range bridge.servers {
+		if e := server.Stop(); err == nil {
+			err = e
+		}
+	}
+
+	// the readers are interrupted and close their connections
+
+	for c := range bridge.conns {
+		DebugError(c.conn.SetReadDeadline(time.Now()))
+	}
+
+	subscriptions := bridge.subscriptions
+	bridge.subscriptions = nil
+
+	bridge.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.Unsubscribe()
+	}
+
+	bridge.wg.Wait()
+
+	return err
+}
+
+func (bridge *EventBridge) connectionCount() int {
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+
+	return len(bridge.conns)
+}
+
+// markSeen returns false if the message id was already relayed
+func (bridge *EventBridge) markSeen(id string) bool {
+	_, ok := bridge.seen[id]
+	if ok {
+		return false
+	}
+
+	delete(bridge.seen, bridge.seenRing[bridge.seenIndex])
+
+	bridge.seen[id] = struct{}{}
+	bridge.seenRing[bridge.seenIndex] = id
+	bridge.seenIndex = (bridge.seenIndex + 1) % len(bridge.seenRing)
+
+	return true
+}
+
+// broadcast sends the line to all connections except the source, slow connections miss lines instead of blocking the emitter
+func (bridge *EventBridge) broadcast(line []byte, source *eventBridgeConn) {
+	for c := range bridge.conns {
+		if c == source {
+			continue
+		}
+
+		select {
+		case c.out <- line:
+		default:
+			Debug("event bridge queue is full, event is dropped: %s", c.conn.Socket.RemoteAddr())
+		}
+	}
+}
+
+func (bridge *EventBridge) publish(eventType reflect.Type, name string, event any) error {
+	bridge.mu.Lock()
+	defer bridge.mu.Unlock()
+
+	// the event was received from the network and is emitted locally by this goroutine
+
+	if bridge.relaying[GoRoutineId()] == eventType {
+		return nil
+	}
+
+	if len(bridge.conns) == 0 {
+		return nil
+	}
+
+	ba, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	msg := eventBridgeMessage{
+		Id:     uuid.New().String(),
+		Origin: bridge.nodeId,
+		Type:   name,
+		Event:  ba,
+	}
+
+	line, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	bridge.markSeen(msg.Id)
+	bridge.broadcast(append(line, '\n'), nil)
+
+	return nil
+}
+
+func (bridge *EventBridge) receive(c *eventBridgeConn, line []byte) error {
+	msg := eventBridgeMessage{}
+
+	err := json.Unmarshal(line, &msg)
+	if err != nil {
+		return err
+	}
+
+	bridge.mu.Lock()
+
+	if msg.Origin == bridge.nodeId || !bridge.markSeen(msg.Id) {
+		bridge.mu.Unlock()
+
+		return nil
+	}
+
+	// events are forwarded even if their type is not registered here
+
+	bridge.broadcast(append(slices.Clone(line), '\n'), c)
+
+	eventType, ok := bridge.types[msg.Type]
+
+	bridge.mu.Unlock()
+
+	if !ok {
+		Debug("event type not registered: %s", msg.Type)
+
+		return nil
+	}
+
+	event := reflect.New(eventType)
+
+	err = json.Unmarshal(msg.Event, event.Interface())
+	if err != nil {
+		return err
+	}
+
+	id := GoRoutineId()
+
+	bridge.mu.Lock()
+	bridge.relaying[id] = eventType
+	bridge.mu.Unlock()
+
+	defer func() {
+		bridge.mu.Lock()
+		delete(bridge.relaying, id)
+		bridge.mu.Unlock()
+	}()
+
+	return bridge.eventManager.EmitE(event.Elem().Interface(), false)
+}
+
+// serve relays the events of the connection until it is closed
+func (bridge *EventBridge) serve(conn *NetworkConnection) {
+	Debug("event bridge connected: %s", conn.Socket.RemoteAddr())
+
+	c := &eventBridgeConn{
+		conn: conn,
+		out:  make(chan []byte, eventBridgeQueueSize),
+	}
+
+	bridge.mu.Lock()
+
+	if bridge.ctx.Err() != nil {
+		bridge.mu.Unlock()
+
+		DebugError(conn.Close())
+
+		return
+	}
+
+	bridge.conns[c] = struct{}{}
+
+	bridge.mu.Unlock()
+
+	done := make(chan struct{})
+
+	go func() {
+		defer close(done)
+
+		for line := range c.out {
+			_, err := conn.Write(line)
+			if DebugError(err) {
+				DebugError(conn.SetReadDeadline(time.Now()))
+
+				// drain until the reader has unregistered the connection
+
+				for range c.out {
+				}
+
+				return
+			}
+		}
+	}()
+
+	scanner := bufio.NewScanner(conn)
+	scanner.Buffer(make([]byte, 0, 64*1024), eventBridgeMaxLineSize)
+
+	for scanner.Scan() {
+		DebugError(bridge.receive(c, scanner.Bytes()))
+	}
+
+	if !IsErrTimeout(scanner.Err()) {
+		DebugError(scanner.Err())
+	}
+
+	bridge.mu.Lock()
+	delete(bridge.conns, c)
+	close(c.out)
+	bridge.mu.Unlock()
+
+	<-done
+
+	DebugError(conn.Close())
+
+	Debug("event bridge disconnected: %s", conn.Socket.RemoteAddr())
+}
@@ -0,0 +1,100 @@
+package common
+
+import (
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync"
+	"testing"
+	"time"
+)
+
+type bridgeEvent struct {
+	Value string
+}
+
+func TestEventBridge(t *testing.T) {
+	port, err := FindFreePort("tcp", 1024, nil)
+	require.NoError(t, err)
+
+	address := fmt.Sprintf("localhost:%d", port)
+
+	type node struct {
+		eventManager *EventManager
+		bridge       *EventBridge
+		mu           sync.Mutex
+		received     []string
+	}
+
+	newNode := func() *node {
+		n := &node{
+			eventManager: NewEventManager(),
+		}
+
+		n.bridge = NewEventBridge(n.eventManager)
+		n.bridge.ReconnectDelay = 50 * time.Millisecond
+
+		require.NoError(t, RegisterBridgeEvent[bridgeEvent](n.bridge, "bridge"))
+
+		Subscribe(n.eventManager, 0, func(e bridgeEvent) error {
+			n.mu.Lock()
+			defer n.mu.Unlock()
+
+			n.received = append(n.received, e.Value)
+
+			return nil
+		})
+
+		return n
+	}
+
+	receivedOf := func(n *node) []string {
+		n.mu.Lock()
+		defer n.mu.Unlock()
+
+		return append([]string{}, n.received...)
+	}
+
+	hub := newNode()
+	client1 := newNode()
+	client2 := newNode()
+
+	var errType *ErrEventBridgeType
+	require.ErrorAs(t, RegisterBridgeEvent[bridgeEvent](hub.bridge, "bridge"), &errType)
+
+	// the clients dial before the hub listens and reconnect
+
+	require.NoError(t, client1.bridge.Dial(address, nil))
+	require.NoError(t, client2.bridge.Dial(address, nil))
+
+	time.Sleep(100 * time.Millisecond)
+
+	require.NoError(t, hub.bridge.Listen(address, nil))
+
+	require.Eventually(t, func() bool {
+		return hub.bridge.connectionCount() == 2 && client1.bridge.connectionCount() == 1 && client2.bridge.connectionCount() == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, Publish(client1.eventManager, bridgeEvent{"from client1"}))
+
+	require.Eventually(t, func() bool {
+		return len(receivedOf(hub)) == 1 && len(receivedOf(client2)) == 1
+	}, 5*time.Second, 10*time.Millisecond)
+
+	require.NoError(t, Publish(hub.eventManager, bridgeEvent{"from hub"}))
+
+	require.Eventually(t, func() bool {
+		return len(receivedOf(client1)) == 2 && len(receivedOf(client2)) == 2
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// no event is relayed back to its origin
+
+	time.Sleep(200 * time.Millisecond)
+
+	require.Equal(t, []string{"from client1", "from hub"}, receivedOf(client1))
+	require.Equal(t, []string{"from client1", "from hub"}, receivedOf(client2))
+	require.Equal(t, []string{"from client1", "from hub"}, receivedOf(hub))
+
+	require.NoError(t, client1.bridge.Close())
+	require.NoError(t, client2.bridge.Close())
+	require.NoError(t, hub.bridge.Close())
+}
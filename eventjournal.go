@@ -0,0 +1,393 @@
+package common
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"reflect"
+	"sync"
+	"time"
+)
+
+const (
+	eventJournalMaxLineSize = 16 * 1024 * 1024
+)
+
+// EventJournalRecord is a journaled event. The offset is the ascending sequence number of the record in the journal.
+type EventJournalRecord struct {
+	Offset int64           `json:"offset"`
+	Time   time.Time       `json:"time"`
+	Type   string          `json:"type"`
+	Event  json.RawMessage `json:"event"`
+}
+
+// EventJournalStore persists the records of an EventJournal
+type EventJournalStore interface {
+	// Append stores the record with the next offset
+	Append(record *EventJournalRecord) error
+	// Read calls fn for all records with an offset >= from in ascending order
+	Read(from int64, fn func(record *EventJournalRecord) error) error
+	// Compact removes all records older than before, the offsets of the remaining records are not changed
+	Compact(before time.Time) error
+	Close() error
+}
+
+type ErrEventJournalType struct {
+	Name string
+}
+
+func (e *ErrEventJournalType) Error() string {
+	return fmt.Sprintf("event type already journaled: %s", e.Name)
+}
+
+// EventJournal appends the emitted events of the journaled types to a store and replays them
+type EventJournal struct {
+	mu            sync.Mutex
+	eventManager  *EventManager
+	store         EventJournalStore
+	types         map[string]reflect.Type
+	subscriptions []*Subscription
+}
+
+func NewEventJournal(eventManager *EventManager, store EventJournalStore) *EventJournal {
+	return &EventJournal{
+		eventManager: eventManager,
+		store:        store,
+		types:        make(map[string]reflect.Type),
+	}
+}
+
+// JournalEvent appends all events of type T by the name. The name identifies the type in the store and must not change.
+func JournalEvent[T any](journal *EventJournal, name string) error {
+	DebugFunc(name)
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	_, ok := journal.types[name]
+	if ok {
+		return &ErrEventJournalType{Name: name}
+	}
+
+	journal.types[name] = reflect.TypeFor[T]()
+
+	journal.subscriptions = append(journal.subscriptions, Subscribe(journal.eventManager, 0, func(event T) error {
+		return journal.append(name, event)
+	}))
+
+	return nil
+}
+
+func (journal *EventJournal) append(name string, event any) error {
+	ba, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	return journal.store.Append(&EventJournalRecord{
+		Time:  time.Now().UTC(),
+		Type:  name,
+		Event: ba,
+	})
+}
+
+// Replay calls fn with all journaled events of the registered types with an offset >= from.
+// Records of types which are not registered are skipped.
+func (journal *EventJournal) Replay(from int64, fn func(offset int64, event Event) error) error {
+	DebugFunc(from)
+
+	journal.mu.Lock()
+	types := make(map[string]reflect.Type, len(journal.types))
+	for name, eventType := range journal.types {
+		types[name] = eventType
+	}
+	journal.mu.Unlock()
+
+	return journal.store.Read(from, func(record *EventJournalRecord) error {
+		eventType, ok := types[record.Type]
+		if !ok {
+			return nil
+		}
+
+		event := reflect.New(eventType)
+
+		err := json.Unmarshal(record.Event, event.Interface())
+		if err != nil {
+			return err
+		}
+
+		return fn(record.Offset, event.Elem().Interface())
+	})
+}
+
+// ReplayEvents calls fn with all journaled events of type T with an offset >= from
+func ReplayEvents[T any](journal *EventJournal, from int64, fn func(offset int64, event T) error) error {
+	return journal.Replay(from, func(offset int64, event Event) error {
+		e, ok := event.(T)
+		if !ok {
+			return nil
+		}
+
+		return fn(offset, e)
+	})
+}
+
+// Compact removes all records older than maxAge
+func (journal *EventJournal) Compact(maxAge time.Duration) error {
+	DebugFunc(maxAge)
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	return journal.store.Compact(time.Now().UTC().Add(-maxAge))
+}
+
+// Close stops journaling and closes the store
+func (journal *EventJournal) Close() error {
+	DebugFunc()
+
+	journal.mu.Lock()
+	subscriptions := journal.subscriptions
+	journal.subscriptions = nil
+	journal.mu.Unlock()
+
+	for _, subscription := range subscriptions {
+		subscription.Unsubscribe()
+	}
+
+	journal.mu.Lock()
+	defer journal.mu.Unlock()
+
+	return journal.store.Close()
+}
+
+// FileEventJournalStore stores the records as JSON lines in an append-only file.
+// A compacted file starts with a record without type which keeps the last used offset.
+type FileEventJournalStore struct {
+	path       string
+	file       *os.File
+	nextOffset int64
+}
+
+func NewFileEventJournalStore(path string) (*FileEventJournalStore, error) {
+	store := &FileEventJournalStore{
+		path: path,
+	}
+
+	// the next offset continues after the last record
+
+	if FileExists(path) {
+		err := truncateTornLine(path)
+		if Error(err) {
+			return nil, err
+		}
+
+		err = store.scan(func(record *EventJournalRecord) error {
+			store.nextOffset = record.Offset + 1
+
+			return nil
+		})
+		if Error(err) {
+			return nil, err
+		}
+	}
+
+	err := store.open()
+	if Error(err) {
+		return nil, err
+	}
+
+	return store, nil
+}
+
+// truncateTornLine removes the incomplete last line of a crashed process, so the next record starts on a new line
+func truncateTornLine(path string) error {
+	f, err := os.OpenFile(path, os.O_RDWR, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		DebugError(f.Close())
+	}()
+
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, 4096)
+
+	for end := fi.Size(); end > 0; {
+		n := Min(int64(len(buf)), end)
+
+		_, err := f.ReadAt(buf[:n], end-n)
+		if err != nil {
+			return err
+		}
+
+		p := bytes.LastIndexByte(buf[:n], '\n')
+		if p != -1 {
+			size := end - n + int64(p) + 1
+			if size == fi.Size() {
+				return nil
+			}
+
+			return f.Truncate(size)
+		}
+
+		end -= n
+	}
+
+	return f.Truncate(0)
+}
+
+func (store *FileEventJournalStore) open() error {
+	var err error
+
+	store.file, err = os.OpenFile(store.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, DefaultFileMode)
+
+	return err
+}
+
+func (store *FileEventJournalStore) Append(record *EventJournalRecord) error {
+	record.Offset = store.nextOffset
+
+	ba, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	_, err = store.file.Write(append(ba, '\n'))
+	if err != nil {
+		return err
+	}
+
+	store.nextOffset++
+
+	return nil
+}
+
+func (store *FileEventJournalStore) Read(from int64, fn func(record *EventJournalRecord) error) error {
+	return store.scan(func(record *EventJournalRecord) error {
+		if record.Type == "" || record.Offset < from {
+			return nil
+		}
+
+		return fn(record)
+	})
+}
+
+// scan calls fn for all records including the offset record of a compaction
+func (store *FileEventJournalStore) scan(fn func(record *EventJournalRecord) error) error {
+	f, err := os.Open(store.path)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		DebugError(f.Close())
+	}()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), eventJournalMaxLineSize)
+
+	for scanner.Scan() {
+		record := &EventJournalRecord{}
+
+		// a torn line of a crashed process is skipped
+
+		err := json.Unmarshal(scanner.Bytes(), record)
+		if DebugError(err) {
+			continue
+		}
+
+		err = fn(record)
+		if err != nil {
+			return err
+		}
+	}
+
+	return scanner.Err()
+}
+
+func (store *FileEventJournalStore) Compact(before time.Time) error {
+	tmpPath := store.path + ".tmp"
+
+	tmp, err := os.OpenFile(tmpPath, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	w := bufio.NewWriter(tmp)
+
+	write := func(record *EventJournalRecord) error {
+		ba, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+
+		_, err = w.Write(append(ba, '\n'))
+
+		return err
+	}
+
+	// the offsets are not reused, even if all records are removed
+
+	if store.nextOffset > 0 {
+		err = write(&EventJournalRecord{
+			Offset: store.nextOffset - 1,
+			Time:   time.Now().UTC(),
+		})
+	}
+
+	if err == nil {
+		err = store.Read(0, func(record *EventJournalRecord) error {
+			if record.Time.Before(before) {
+				return nil
+			}
+
+			return write(record)
+		})
+	}
+
+	if err == nil {
+		err = w.Flush()
+	}
+
+	closeErr := tmp.Close()
+	if err == nil {
+		err = closeErr
+	}
+
+	if err != nil {
+		DebugError(os.Remove(tmpPath))
+
+		return err
+	}
+
+	// the compacted file replaces the journal atomically
+
+	err = store.file.Close()
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmpPath, store.path)
+
+	openErr := store.open()
+	if err == nil {
+		err = openErr
+	}
+
+	return err
+}
+
+func (store *FileEventJournalStore) Close() error {
+	return store.file.Close()
+}
@@ -0,0 +1,99 @@
+package common
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type journalEvent struct {
+	Value int
+}
+
+func TestEventJournal(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "journal.jsonl")
+
+	eventManager := NewEventManager()
+
+	store, err := NewFileEventJournalStore(path)
+	require.NoError(t, err)
+
+	journal := NewEventJournal(eventManager, store)
+
+	require.NoError(t, JournalEvent[journalEvent](journal, "journal"))
+	require.NoError(t, JournalEvent[EventShutdown](journal, "shutdown"))
+
+	var errType *ErrEventJournalType
+	require.ErrorAs(t, JournalEvent[journalEvent](journal, "journal"), &errType)
+
+	for i := range 3 {
+		require.NoError(t, Publish(eventManager, journalEvent{i}))
+	}
+	require.NoError(t, Publish(eventManager, EventShutdown{}))
+
+	require.NoError(t, journal.Close())
+
+	// the offsets continue after a restart
+
+	store, err = NewFileEventJournalStore(path)
+	require.NoError(t, err)
+
+	journal = NewEventJournal(eventManager, store)
+	require.NoError(t, JournalEvent[journalEvent](journal, "journal"))
+
+	require.NoError(t, Publish(eventManager, journalEvent{3}))
+
+	var offsets []int64
+	var values []int
+
+	require.NoError(t, ReplayEvents(journal, 1, func(offset int64, event journalEvent) error {
+		offsets = append(offsets, offset)
+		values = append(values, event.Value)
+
+		return nil
+	}))
+
+	require.Equal(t, []int64{1, 2, 4}, offsets)
+	require.Equal(t, []int{1, 2, 3}, values)
+
+	// compaction keeps the offsets
+
+	require.NoError(t, journal.Compact(-time.Hour))
+
+	require.NoError(t, journal.Replay(0, func(offset int64, event Event) error {
+		require.Fail(t, "no records after compaction")
+
+		return nil
+	}))
+
+	require.NoError(t, journal.Close())
+
+	// the offsets are not reused after a restart and a torn last line of a crash is removed
+
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, DefaultFileMode)
+	require.NoError(t, err)
+	_, err = f.WriteString(`{"offset":99,"ty`)
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+
+	store, err = NewFileEventJournalStore(path)
+	require.NoError(t, err)
+
+	journal = NewEventJournal(eventManager, store)
+	require.NoError(t, JournalEvent[journalEvent](journal, "journal"))
+
+	require.NoError(t, Publish(eventManager, journalEvent{5}))
+
+	offsets = nil
+
+	require.NoError(t, journal.Replay(0, func(offset int64, event Event) error {
+		offsets = append(offsets, offset)
+
+		return nil
+	}))
+	require.Equal(t, []int64{5}, offsets)
+
+	require.NoError(t, journal.Close())
+}
@@ -1,110 +1,302 @@
 package common
 
 import (
+	"errors"
 	"fmt"
+	"reflect"
 	"slices"
 	"sync"
 )
 
+// Event is any value. Listeners are registered by the dynamic type of the event, so T and *T are different events.
+//
+// Nested emits: an emit from within a listener is dispatched synchronously before the outer emit continues.
+// An emit of an event type which is already dispatched by the same goroutine is rejected with ErrEventNested
+// to prevent endless recursion. Emits of the same type from other goroutines, also from async listeners, are not affected.
 type Event interface{}
 
 type EventFunc func(Event)
 
+type ErrEventNested struct {
+	Type string
+}
+
+func (e *ErrEventNested) Error() string {
+	return fmt.Sprintf("nested emit of event %s is rejected", e.Type)
+}
+
+type ErrEventQueueFull struct {
+	Type string
+}
+
+func (e *ErrEventQueueFull) Error() string {
+	return fmt.Sprintf("event queue is full, event %s is dropped", e.Type)
+}
+
+type eventListener struct {
+	id       any
+	priority int
+	fn       func(Event) error
+	queue    chan Event
+	done     chan struct{}
+	mu       sync.RWMutex
+	closed   bool
+}
+
 type EventManager struct {
-	listeners    map[string][]*EventFunc
-	currentEmits []string
+	listeners map[reflect.Type][]*eventListener
+	// currentEmits are the event types dispatched by each goroutine
+	currentEmits map[uint64][]reflect.Type
 	sync.Mutex
 }
 
+// Subscription is a listener registered by Subscribe or SubscribeAsync
+type Subscription struct {
+	eventManager *EventManager
+	listener     *eventListener
+}
+
 var (
 	Events = NewEventManager()
 )
 
 func NewEventManager() *EventManager {
 	return &EventManager{
-		listeners: make(map[string][]*EventFunc),
+		listeners:    make(map[reflect.Type][]*eventListener),
+		currentEmits: make(map[uint64][]reflect.Type),
 	}
 }
 
-func (eventManager *EventManager) AddListener(event interface{}, eventFunc EventFunc) *EventFunc {
-	eventType := fmt.Sprintf("%T", event)
+func (eventManager *EventManager) addListener(eventType reflect.Type, listener *eventListener) {
+	eventManager.Lock()
+	defer eventManager.Unlock()
 
-	DebugFunc("%T", event)
+	// listeners with higher priority first, same priority in order of registration
 
-	if _, ok := eventManager.listeners[eventType]; ok {
-		eventManager.listeners[eventType] = append(eventManager.listeners[eventType], &eventFunc)
-	} else {
-		eventManager.listeners[eventType] = []*EventFunc{&eventFunc}
+	funcs := eventManager.listeners[eventType]
+
+	p := slices.IndexFunc(funcs, func(l *eventListener) bool {
+		return l.priority < listener.priority
+	})
+	if p == -1 {
+		p = len(funcs)
 	}
 
-	return &eventFunc
+	eventManager.listeners[eventType] = slices.Insert(funcs, p, listener)
 }
 
-func (eventManager *EventManager) RemoveListener(eventFunc *EventFunc) {
-	DebugFunc()
+func (eventManager *EventManager) removeListener(id any) *eventListener {
+	eventManager.Lock()
+	defer eventManager.Unlock()
 
 	for eventType, funcs := range eventManager.listeners {
-		for i := range funcs {
-			if funcs[i] == eventFunc {
-				funcs = SliceDelete(funcs, i)
-				break
-			}
+		p := slices.IndexFunc(funcs, func(l *eventListener) bool {
+			return l.id == id
+		})
+		if p == -1 {
+			continue
 		}
 
+		listener := funcs[p]
+
+		funcs = slices.Delete(slices.Clone(funcs), p, p+1)
+
 		if len(funcs) == 0 {
 			delete(eventManager.listeners, eventType)
 		} else {
 			eventManager.listeners[eventType] = funcs
 		}
+
+		return listener
+	}
+
+	return nil
+}
+
+func (eventManager *EventManager) AddListener(event interface{}, eventFunc EventFunc) *EventFunc {
+	DebugFunc("%T", event)
+
+	eventManager.addListener(reflect.TypeOf(event), &eventListener{
+		id: &eventFunc,
+		fn: func(event Event) error {
+			eventFunc(event)
+
+			return nil
+		},
+	})
+
+	return &eventFunc
+}
+
+func (eventManager *EventManager) RemoveListener(eventFunc *EventFunc) {
+	DebugFunc()
+
+	eventManager.removeListener(eventFunc)
+}
+
+func subscribe[T any](eventManager *EventManager, priority int, queueSize int, fn func(T) error) *Subscription {
+	listener := &eventListener{
+		priority: priority,
+		fn: func(event Event) error {
+			return fn(event.(T))
+		},
+	}
+	listener.id = listener
+
+	if queueSize > 0 {
+		listener.queue = make(chan Event, queueSize)
+		listener.done = make(chan struct{})
+
+		go func() {
+			defer close(listener.done)
+
+			// there is no emitter to return the error to
+
+			for event := range listener.queue {
+				Error(listener.fn(event))
+			}
+		}()
+	}
+
+	eventManager.addListener(reflect.TypeFor[T](), listener)
+
+	return &Subscription{
+		eventManager: eventManager,
+		listener:     listener,
+	}
+}
+
+// Subscribe registers a listener for events of type T. Listeners with higher priority are called first.
+func Subscribe[T any](eventManager *EventManager, priority int, fn func(T) error) *Subscription {
+	DebugFunc("%v", reflect.TypeFor[T]())
+
+	return subscribe(eventManager, priority, 0, fn)
+}
+
+// SubscribeAsync registers a listener for events of type T which is called by its own goroutine.
+// Up to queueSize events are buffered, further events are rejected with ErrEventQueueFull. Errors of the listener are logged.
+func SubscribeAsync[T any](eventManager *EventManager, priority int, queueSize int, fn func(T) error) *Subscription {
+	DebugFunc("%v", reflect.TypeFor[T]())
+
+	return subscribe(eventManager, priority, Max(1, queueSize), fn)
+}
+
+// Unsubscribe removes the listener. An async listener handles all queued events before Unsubscribe returns.
+func (subscription *Subscription) Unsubscribe() {
+	DebugFunc()
+
+	listener := subscription.eventManager.removeListener(subscription.listener)
+	if listener == nil || listener.queue == nil {
+		return
 	}
+
+	listener.mu.Lock()
+	listener.closed = true
+	close(listener.queue)
+	listener.mu.Unlock()
+
+	<-listener.done
+}
+
+// Publish emits the event to all listeners of type T
+func Publish[T any](eventManager *EventManager, event T) error {
+	return eventManager.emit(reflect.TypeFor[T](), event, false)
 }
 
-func (eventManager *EventManager) registerEventType(eventType string) bool {
+func (listener *eventListener) enqueue(eventType reflect.Type, event Event) error {
+	listener.mu.RLock()
+	defer listener.mu.RUnlock()
+
+	// the listener may be unsubscribed during the emit
+
+	if listener.closed {
+		return nil
+	}
+
+	select {
+	case listener.queue <- event:
+		return nil
+	default:
+		return &ErrEventQueueFull{Type: eventType.String()}
+	}
+}
+
+func (eventManager *EventManager) registerEventType(id uint64, eventType reflect.Type) bool {
 	eventManager.Lock()
 	defer eventManager.Unlock()
 
-	if slices.Contains(eventManager.currentEmits, eventType) {
+	if slices.Contains(eventManager.currentEmits[id], eventType) {
 		return false
 	}
 
-	eventManager.currentEmits = append(eventManager.currentEmits, eventType)
+	eventManager.currentEmits[id] = append(eventManager.currentEmits[id], eventType)
 
 	return true
 }
 
-func (eventManager *EventManager) unregisterEventType(eventType string) {
+func (eventManager *EventManager) unregisterEventType(id uint64, eventType reflect.Type) {
 	eventManager.Lock()
 	defer eventManager.Unlock()
 
-	p := slices.Index(eventManager.currentEmits, eventType)
+	types := eventManager.currentEmits[id]
+
+	p := slices.Index(types, eventType)
 	if p != -1 {
-		eventManager.currentEmits = slices.Delete(eventManager.currentEmits, p, p+1)
+		types = slices.Delete(types, p, p+1)
+	}
+
+	if len(types) == 0 {
+		delete(eventManager.currentEmits, id)
+	} else {
+		eventManager.currentEmits[id] = types
 	}
 }
 
+// Emit calls all listeners of the event type, with reverse in the opposite order of their priority.
+// The errors of the listeners are only debug logged, see EmitE.
 func (eventManager *EventManager) Emit(event interface{}, reverse bool) {
-	eventType := fmt.Sprintf("%T", event)
+	DebugError(eventManager.EmitE(event, reverse))
+}
 
-	if !eventManager.registerEventType(eventType) {
-		return
-	}
+// EmitE calls all listeners of the event type like Emit, their errors are returned joined.
+func (eventManager *EventManager) EmitE(event interface{}, reverse bool) error {
+	return eventManager.emit(reflect.TypeOf(event), event, reverse)
+}
 
-	defer eventManager.unregisterEventType(eventType)
+func (eventManager *EventManager) emit(eventType reflect.Type, event Event, reverse bool) error {
+	eventManager.Lock()
+	funcs := slices.Clone(eventManager.listeners[eventType])
+	eventManager.Unlock()
 
-	funcs, ok := eventManager.listeners[eventType]
-	if !ok && len(funcs) == 0 {
-		return
+	// most events have no listener, so the expensive goroutine id is only needed for the nested check of the others
+
+	if len(funcs) == 0 {
+		return nil
 	}
 
-	//DebugFunc(eventType)
+	id := GoRoutineId()
+
+	if !eventManager.registerEventType(id, eventType) {
+		return &ErrEventNested{Type: eventType.String()}
+	}
 
-	funcs = slices.Clone(funcs)
+	defer eventManager.unregisterEventType(id, eventType)
 
 	if reverse {
 		funcs = ReverseSlice(funcs)
 	}
 
-	for _, receiver := range funcs {
-		(*receiver)(event)
+	var errs []error
+
+	for _, listener := range funcs {
+		if listener.queue == nil {
+			errs = append(errs, listener.fn(event))
+
+			continue
+		}
+
+		errs = append(errs, listener.enqueue(eventType, event))
 	}
+
+	return errors.Join(errs...)
 }
@@ -1,8 +1,11 @@
 package common
 
 import (
+	"fmt"
 	"github.com/stretchr/testify/require"
+	"sync"
 	"testing"
+	"time"
 )
 
 type boolEvent struct {
@@ -61,3 +64,125 @@ func TestEventsPreventRepeating(t *testing.T) {
 
 	require.Equal(t, e.value, 1)
 }
+
+type stringEvent struct {
+	value string
+}
+
+func TestEventsSubscribe(t *testing.T) {
+	eventManager := NewEventManager()
+
+	var order []int
+
+	for _, priority := range []int{0, 10, 5, 10} {
+		Subscribe(eventManager, priority, func(e stringEvent) error {
+			order = append(order, priority)
+
+			if priority == 5 {
+				return fmt.Errorf("failed %s", e.value)
+			}
+
+			return nil
+		})
+	}
+
+	err := Publish(eventManager, stringEvent{"a"})
+	require.Error(t, err)
+	require.Equal(t, "failed a", err.Error())
+	require.Equal(t, []int{10, 10, 5, 0}, order)
+
+	order = nil
+
+	require.Error(t, eventManager.EmitE(stringEvent{"b"}, true))
+	require.Equal(t, []int{0, 5, 10, 10}, order)
+
+	// pointer events are different events
+
+	require.NoError(t, Publish(eventManager, &stringEvent{"c"}))
+}
+
+func TestEventsUnsubscribe(t *testing.T) {
+	eventManager := NewEventManager()
+
+	count := 0
+
+	subscription := Subscribe(eventManager, 0, func(e intEvent) error {
+		count++
+
+		return nil
+	})
+
+	require.NoError(t, Publish(eventManager, intEvent{}))
+
+	subscription.Unsubscribe()
+
+	require.NoError(t, Publish(eventManager, intEvent{}))
+	require.Equal(t, 1, count)
+}
+
+func TestEventsSubscribeAsync(t *testing.T) {
+	eventManager := NewEventManager()
+
+	block := make(chan struct{})
+	var received []int
+
+	subscription := SubscribeAsync(eventManager, 0, 2, func(e intEvent) error {
+		<-block
+
+		received = append(received, e.value)
+
+		return nil
+	})
+
+	// the first event is taken by the worker, two are queued
+
+	require.NoError(t, Publish(eventManager, intEvent{1}))
+	require.Eventually(t, func() bool {
+		return len(subscription.listener.queue) == 0
+	}, time.Second, time.Millisecond)
+	require.NoError(t, Publish(eventManager, intEvent{2}))
+	require.NoError(t, Publish(eventManager, intEvent{3}))
+
+	err := Publish(eventManager, intEvent{4})
+	var errQueueFull *ErrEventQueueFull
+	require.ErrorAs(t, err, &errQueueFull)
+
+	close(block)
+
+	subscription.Unsubscribe()
+
+	require.Equal(t, []int{1, 2, 3}, received)
+}
+
+func TestEventsNested(t *testing.T) {
+	eventManager := NewEventManager()
+
+	var nestedErr error
+	var otherErr error
+
+	Subscribe(eventManager, 0, func(e intEvent) error {
+		if e.value > 0 {
+			return nil
+		}
+
+		nestedErr = Publish(eventManager, intEvent{1})
+
+		// the same type emitted by another goroutine is dispatched
+
+		wg := sync.WaitGroup{}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			otherErr = Publish(eventManager, intEvent{2})
+		}()
+		wg.Wait()
+
+		return nil
+	})
+
+	require.NoError(t, Publish(eventManager, intEvent{0}))
+	var errNested *ErrEventNested
+	require.ErrorAs(t, nestedErr, &errNested)
+	require.NoError(t, otherErr)
+}
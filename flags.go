@@ -1,12 +1,22 @@
 package common
 
 import (
+	"errors"
 	"flag"
 	"fmt"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 var (
 	SystemFlagNames []string
+
+	flagValidators      = make(map[string][]FlagValidator)
+	flagValidatorsMutex sync.Mutex
 )
 
 type ErrFlagNotDefined struct {
@@ -17,26 +27,201 @@ func (e *ErrFlagNotDefined) Error() string {
 	return fmt.Sprintf("Flags must be defined: %s", e.Name)
 }
 
-func SystemFlagBool(name string, value bool, usage string) *bool {
+type ErrFlagInvalid struct {
+	Name   string
+	Value  string
+	Origin string
+	Secret bool
+	Err    error
+}
+
+func (e *ErrFlagInvalid) Error() string {
+	value := maskFlagValue(e.Name, e.Value, e.Secret)
+
+	msg := fmt.Sprintf("invalid flag value %s=%s", e.Name, value)
+	if e.Origin != "" {
+		msg = fmt.Sprintf("invalid flag value in %s %s=%s", e.Origin, e.Name, value)
+	}
+
+	// validators may quote the value in their message, so it is omitted for masked values
+
+	if value != e.Value {
+		return msg
+	}
+
+	return fmt.Sprintf("%s: %s", msg, e.Err.Error())
+}
+
+func (e *ErrFlagInvalid) Unwrap() error {
+	return e.Err
+}
+
+// FlagValidator checks the string representation of a flag value
+type FlagValidator func(value string) error
+
+func SystemFlagBool(name string, value bool, usage string, validators ...FlagValidator) *bool {
 	SystemFlagNames = append(SystemFlagNames, name)
+	RegisterFlagValidators(name, validators...)
 
 	return flag.Bool(name, value, usage)
 }
 
-func SystemFlagInt(name string, value int, usage string) *int {
+func SystemFlagInt(name string, value int, usage string, validators ...FlagValidator) *int {
 	SystemFlagNames = append(SystemFlagNames, name)
+	RegisterFlagValidators(name, validators...)
 
 	return flag.Int(name, value, usage)
 }
 
-func SystemFlagInt64(name string, value int64, usage string) *int64 {
+func SystemFlagInt64(name string, value int64, usage string, validators ...FlagValidator) *int64 {
 	SystemFlagNames = append(SystemFlagNames, name)
+	RegisterFlagValidators(name, validators...)
 
 	return flag.Int64(name, value, usage)
 }
 
-func SystemFlagString(name string, value string, usage string) *string {
+func SystemFlagString(name string, value string, usage string, validators ...FlagValidator) *string {
 	SystemFlagNames = append(SystemFlagNames, name)
+	RegisterFlagValidators(name, validators...)
 
 	return flag.String(name, value, usage)
 }
+
+// RegisterFlagValidators adds validators to a flag which are checked after all flag sources are merged
+func RegisterFlagValidators(name string, validators ...FlagValidator) {
+	if len(validators) == 0 {
+		return
+	}
+
+	flagValidatorsMutex.Lock()
+	defer flagValidatorsMutex.Unlock()
+
+	flagValidators[name] = append(flagValidators[name], validators...)
+}
+
+// ValidateFlag checks the value against all registered validators of the flag
+func ValidateFlag(name string, value string) error {
+	flagValidatorsMutex.Lock()
+	validators := slices.Clone(flagValidators[name])
+	flagValidatorsMutex.Unlock()
+
+	for _, validator := range validators {
+		err := validator(value)
+		if err != nil {
+			return &ErrFlagInvalid{
+				Name:  name,
+				Value: value,
+				Err:   err,
+			}
+		}
+	}
+
+	return nil
+}
+
+// ValidateFlags checks all flags and returns all violations at once
+func ValidateFlags() error {
+	return validateFlagValues(FlagOrigins())
+}
+
+func validateFlagValues(infos map[string]FlagInfo) error {
+	var errs []error
+
+	for _, name := range SortedKeys(infos) {
+		info := infos[name]
+
+		err := ValidateFlag(name, info.Value)
+		if err != nil {
+			errFlag := err.(*ErrFlagInvalid)
+			errFlag.Origin = info.Origin
+			errFlag.Secret = info.Secret
+
+			errs = append(errs, errFlag)
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// ValidateRange accepts an integer value between min and max (inclusive)
+func ValidateRange(min int64, max int64) FlagValidator {
+	return func(value string) error {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return fmt.Errorf("not an integer")
+		}
+
+		if n < min || n > max {
+			return fmt.Errorf("not in range [%d,%d]", min, max)
+		}
+
+		return nil
+	}
+}
+
+// ValidateRegex accepts a value which matches the regular expression
+func ValidateRegex(expr string) FlagValidator {
+	re := regexp.MustCompile(expr)
+
+	return func(value string) error {
+		if !re.MatchString(value) {
+			return fmt.Errorf("does not match %s", expr)
+		}
+
+		return nil
+	}
+}
+
+// ValidateEnum accepts exactly one of the values
+func ValidateEnum(values ...string) FlagValidator {
+	return func(value string) error {
+		if !slices.Contains(values, value) {
+			return fmt.Errorf("not one of %v", values)
+		}
+
+		return nil
+	}
+}
+
+// ValidateOptions accepts an empty value or a comma separated list of options as supported by NewOptions
+func ValidateOptions(allOptions ...string) FlagValidator {
+	return func(value string) error {
+		_, err := NewOptions(allOptions, Split(value, ","))
+
+		return err
+	}
+}
+
+// ValidateFileExists accepts an empty value or the path of an existing file
+func ValidateFileExists() FlagValidator {
+	return func(value string) error {
+		if value != "" && !FileExists(value) {
+			return &ErrFileNotFound{
+				FileName: value,
+			}
+		}
+
+		return nil
+	}
+}
+
+// ValidateDuration accepts a value which can be parsed by time.ParseDuration
+func ValidateDuration() FlagValidator {
+	return func(value string) error {
+		_, err := time.ParseDuration(value)
+
+		return err
+	}
+}
+
+// ValidatePort accepts a TCP/UDP port number or a listen address like ":8080" or "localhost:8080"
+func ValidatePort() FlagValidator {
+	return func(value string) error {
+		p := strings.LastIndex(value, ":")
+		if p != -1 {
+			value = value[p+1:]
+		}
+
+		return ValidateRange(1, 65535)(value)
+	}
+}
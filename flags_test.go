@@ -0,0 +1,85 @@
+package common
+
+import (
+	"flag"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+var (
+	flagValidateTestInt  = flag.Int("validatetest.int", 5, "validate test int")
+	flagValidateTestPort = flag.String("validatetest.port", ":8080", "validate test port")
+)
+
+func init() {
+	RegisterFlagValidators("validatetest.int", ValidateRange(1, 10))
+	RegisterFlagValidators("validatetest.port", ValidatePort())
+}
+
+func TestFlagValidators(t *testing.T) {
+	require.NoError(t, ValidateRange(1, 10)("1"))
+	require.NoError(t, ValidateRange(1, 10)("10"))
+	require.Error(t, ValidateRange(1, 10)("0"))
+	require.Error(t, ValidateRange(1, 10)("x"))
+
+	require.NoError(t, ValidateRegex("^[a-z]+$")("abc"))
+	require.Error(t, ValidateRegex("^[a-z]+$")("ABC"))
+
+	require.NoError(t, ValidateEnum("", "a", "b")(""))
+	require.NoError(t, ValidateEnum("", "a", "b")("b"))
+	require.Error(t, ValidateEnum("", "a", "b")("c"))
+
+	require.NoError(t, ValidateOptions("a", "b", "c")(""))
+	require.NoError(t, ValidateOptions("a", "b", "c")("a,-b"))
+	require.Error(t, ValidateOptions("a", "b", "c")("a,d"))
+
+	require.NoError(t, ValidateFileExists()(""))
+	require.NoError(t, ValidateFileExists()("flags_test.go"))
+	require.ErrorAs(t, ValidateFileExists()("notexisting.txt"), new(*ErrFileNotFound))
+
+	require.NoError(t, ValidateDuration()("1m30s"))
+	require.Error(t, ValidateDuration()("90"))
+
+	require.NoError(t, ValidatePort()("8080"))
+	require.NoError(t, ValidatePort()(":8080"))
+	require.NoError(t, ValidatePort()("localhost:8080"))
+	require.Error(t, ValidatePort()("0"))
+	require.Error(t, ValidatePort()("localhost:70000"))
+}
+
+func TestValidateFlagValues(t *testing.T) {
+	require.NoError(t, ValidateFlag("validatetest.int", "5"))
+	require.NoError(t, ValidateFlag("validatetest.unknown", "whatever"))
+
+	err := validateFlagValues(map[string]FlagInfo{
+		"validatetest.int":  {Value: "11", Origin: "cfg file"},
+		"validatetest.port": {Value: "x", Origin: "env"},
+	})
+	require.Error(t, err)
+
+	// all violations are reported at once including the origin
+
+	require.Contains(t, err.Error(), "invalid flag value in cfg file validatetest.int=11")
+	require.Contains(t, err.Error(), "invalid flag value in env validatetest.port=x")
+
+	errFlag := &ErrFlagInvalid{}
+	require.ErrorAs(t, err, &errFlag)
+	require.Equal(t, "validatetest.int", errFlag.Name)
+
+	// resolved secret values are never shown
+
+	err = validateFlagValues(map[string]FlagInfo{
+		"validatetest.port": {Value: "plaintext", Origin: "env", Secret: true},
+	})
+	require.Error(t, err)
+	require.NotContains(t, err.Error(), "plaintext")
+
+	// short secrets do not garble the message of the validator
+
+	err = validateFlagValues(map[string]FlagInfo{
+		"validatetest.int":  {Value: "0", Origin: "env", Secret: true},
+		"validatetest.port": {Value: "0", Origin: "env"},
+	})
+	require.Error(t, err)
+	require.Equal(t, "invalid flag value in env validatetest.int=XXXXX...\ninvalid flag value in env validatetest.port=0: not in range [1,65535]", err.Error())
+}
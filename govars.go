@@ -7,6 +7,7 @@ import (
 
 const (
 	goVarslastLogEntry = "LAST_LOG_ENTRY"
+	goVarsRequestId    = "REQUEST_ID"
 )
 
 type goRoutineVars map[uint64]map[string]any
@@ -32,7 +32,8 @@ const (
 
 	ACCEPT_ENCODING = "Accept-Encoding"
 
-	HEADER_LOCATION = "Location"
+	HEADER_LOCATION   = "Location"
+	HEADER_REQUEST_ID = "X-Request-ID"
 
 	FlagNameHTTPHeaderLimit   = "http.headerlimit"
 	FlagNameHTTPBodyLimit     = "http.bodylimit"
@@ -102,26 +103,62 @@ func Header(r *http.Request, name string) (string, error) {
 type StatusResponseWriter struct {
 	http.ResponseWriter
 	statusCode int
+	written    bool
 }
 
 func (w *StatusResponseWriter) WriteHeader(code int) {
 	w.statusCode = code
+	w.written = true
 	w.ResponseWriter.WriteHeader(code)
 }
 
+func (w *StatusResponseWriter) Write(p []byte) (int, error) {
+	w.written = true
+
+	return w.ResponseWriter.Write(p)
+}
+
 func NewStatusResponseWriter(w http.ResponseWriter) *StatusResponseWriter {
 	// Set statusCode to 200 by default in case WriteHeader is not explicitly called.
 	return &StatusResponseWriter{ResponseWriter: w, statusCode: http.StatusOK}
 }
 
-func TelemetryHandler(next http.HandlerFunc) http.HandlerFunc {
+// RequestIdHandler propagates a valid "X-Request-ID" header or assigns a new request id.
+// The request id is returned as response header, stored in the request context and bound to the handler goroutine for logging.
+func RequestIdHandler(next http.HandlerFunc) http.HandlerFunc {
 	DebugFunc()
 
 	return func(w http.ResponseWriter, r *http.Request) {
+		id := RequestIdFromContext(r.Context())
+
+		if id == "" {
+			id = r.Header.Get(HEADER_REQUEST_ID)
+			if !IsValidRequestId(id) {
+				id = NewRequestId()
+			}
+
+			r = r.WithContext(ContextWithRequestId(r.Context(), id))
+		}
+
+		w.Header().Set(HEADER_REQUEST_ID, id)
+
+		defer SetGoRoutineRequestId(id)()
+
+		next.ServeHTTP(w, r)
+	}
+}
+
+// TelemetryHandler emits an EventTelemetry for each request. It includes the RequestIdHandler, so every response
+// carries the "X-Request-ID" header, also if the request did not send one.
+func TelemetryHandler(next http.HandlerFunc) http.HandlerFunc {
+	DebugFunc()
+
+	return RequestIdHandler(func(w http.ResponseWriter, r *http.Request) {
 		eventTelemetry := EventTelemetry{
 			IsTelemetryRequest: true,
 			Ctx:                r.Context(),
 			Title:              fmt.Sprintf("%s %s", r.Method, r.URL.String()),
+			RequestId:          RequestIdFromContext(r.Context()),
 			Start:              time.Now(),
 		}
 		defer func() {
@@ -138,7 +175,7 @@ func TelemetryHandler(next http.HandlerFunc) http.HandlerFunc {
 		if eventTelemetry.Code == 0 {
 			eventTelemetry.Code = http.StatusOK
 		}
-	}
+	})
 }
 
 func BasicAuthHandler(mandatory bool, authFunc BasicAuthFunc, next http.HandlerFunc) http.HandlerFunc {
@@ -418,6 +455,10 @@ func HTTPResponse(w http.ResponseWriter, r *http.Request, status int, mimeType s
 func HTTPRequest(httpTransport *http.Transport, timeout time.Duration, method string, address string, headers http.Header, formdata url.Values, username string, password string, body io.Reader, expectedCode int) (*http.Response, []byte, error) {
 	DebugFunc()
 
+	return httpRequest(context.Background(), httpTransport, timeout, method, address, headers, formdata, username, password, body, expectedCode)
+}
+
+func httpRequest(ctx context.Context, httpTransport *http.Transport, timeout time.Duration, method string, address string, headers http.Header, formdata url.Values, username string, password string, body io.Reader, expectedCode int) (*http.Response, []byte, error) {
 	start := time.Now()
 
 	eventTelemetry := EventTelemetry{
@@ -438,6 +479,18 @@ func HTTPRequest(httpTransport *http.Transport, timeout time.Duration, method st
 		headers = make(http.Header)
 	}
 
+	// the request id of the current request is propagated to the called service
+
+	eventTelemetry.RequestId = headers.Get(HEADER_REQUEST_ID)
+	if eventTelemetry.RequestId == "" {
+		eventTelemetry.RequestId = GoRoutineRequestId()
+
+		if eventTelemetry.RequestId != "" {
+			headers = headers.Clone()
+			headers.Set(HEADER_REQUEST_ID, eventTelemetry.RequestId)
+		}
+	}
+
 	connectTimeout := MillisecondToDuration(*FlagIoConnectTimeout)
 
 	if httpTransport == nil {
@@ -465,7 +518,7 @@ func HTTPRequest(httpTransport *http.Transport, timeout time.Duration, method st
 		body = strings.NewReader(formdata.Encode())
 	}
 
-	req, err := http.NewRequest(method, address, body)
+	req, err := http.NewRequestWithContext(ctx, method, address, body)
 	if Error(err) {
 		return nil, nil, err
 	}
@@ -523,7 +576,7 @@ func HTTPRequest(httpTransport *http.Transport, timeout time.Duration, method st
 	var resp *http.Response
 
 	if timeout > 0 {
-		ctx, cancel := context.WithTimeout(context.Background(), timeout)
+		ctx, cancel := context.WithTimeout(ctx, timeout)
 		defer func() {
 			cancel()
 		}()
@@ -574,6 +627,45 @@ func HTTPRequest(httpTransport *http.Transport, timeout time.Duration, method st
 	return resp, ba, nil
 }
 
+// HTTPRequestWithRetry is HTTPRequest with an optional retry policy and circuit breaker. The retries stop when ctx is done.
+// The body is read completely into memory before the first attempt to send it again on each retry,
+// large or streamed bodies should be sent by HTTPRequest.
+func HTTPRequestWithRetry(ctx context.Context, retryPolicy *RetryPolicy, circuitBreaker *CircuitBreaker, httpTransport *http.Transport, timeout time.Duration, method string, address string, headers http.Header, formdata url.Values, username string, password string, body io.Reader, expectedCode int) (*http.Response, []byte, error) {
+	DebugFunc()
+
+	var payload []byte
+
+	if body != nil {
+		var err error
+
+		payload, err = io.ReadAll(body)
+		if Error(err) {
+			return nil, nil, err
+		}
+	}
+
+	var ba []byte
+
+	resp, err := RetryWithBreaker(ctx, retryPolicy, circuitBreaker, func(ctx context.Context) (*http.Response, error) {
+		var body io.Reader
+		if payload != nil {
+			body = bytes.NewReader(payload)
+		}
+
+		var resp *http.Response
+		var err error
+
+		resp, ba, err = httpRequest(ctx, httpTransport, timeout, method, address, headers, formdata, username, password, body, expectedCode)
+
+		return resp, err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return resp, ba, nil
+}
+
 func ReadBody(r io.ReadCloser) ([]byte, error) {
 	defer func() {
 		Error(r.Close())
@@ -631,3 +723,42 @@ func ConcurrentLimitHandler(next http.HandlerFunc) http.HandlerFunc {
 		next.ServeHTTP(w, r)
 	}
 }
+
+// WorkerPoolHandler runs the requests by the pool. If the queue of the pool is full the request is rejected with 503.
+func WorkerPoolHandler(pool *WorkerPool[WorkerFunc, struct{}], next http.HandlerFunc) http.HandlerFunc {
+	DebugFunc()
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		sw := NewStatusResponseWriter(w)
+
+		resultCh, err := pool.TrySubmit(r.Context(), func(ctx context.Context) error {
+			// the request id bound to the goroutine of the handler is bound to the worker too
+
+			if id := RequestIdFromContext(ctx); id != "" {
+				defer SetGoRoutineRequestId(id)()
+			}
+
+			next.ServeHTTP(sw, r.WithContext(ctx))
+
+			return nil
+		})
+		if err != nil {
+			Info(fmt.Sprintf("Request rejected by worker pool: %v", err))
+
+			w.Header().Set("Retry-After", "1")
+
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+
+			return
+		}
+
+		// the response writer is used by the worker, so the handler must wait for the result in any case
+
+		// after a panic the status can only be set if the handler has not started the response
+
+		result := <-resultCh
+		if _, ok := result.Err.(*ErrPanic); ok && !sw.written {
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}
+}
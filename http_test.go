@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/stretchr/testify/require"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 )
@@ -50,3 +51,42 @@ func TestHttpRequest(t *testing.T) {
 
 	require.True(t, IsErrTimeout(err))
 }
+
+func TestRequestIdHandler(t *testing.T) {
+	var ids []string
+
+	handler := RequestIdHandler(func(w http.ResponseWriter, r *http.Request) {
+		require.Equal(t, RequestIdFromContext(r.Context()), GoRoutineRequestId())
+
+		ids = append(ids, GoRoutineRequestId())
+	})
+
+	tests := []struct {
+		name   string
+		header string
+		keep   bool
+	}{
+		{"propagated", "abc-123", true},
+		{"missing", "", false},
+		{"invalid", "abc 123", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if tt.header != "" {
+				r.Header.Set(HEADER_REQUEST_ID, tt.header)
+			}
+
+			w := httptest.NewRecorder()
+
+			handler(w, r)
+
+			id := w.Header().Get(HEADER_REQUEST_ID)
+			require.True(t, IsValidRequestId(id))
+			require.Equal(t, id, ids[len(ids)-1])
+			require.Equal(t, tt.keep, id == tt.header)
+			require.Equal(t, "", GoRoutineRequestId())
+		})
+	}
+}
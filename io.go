@@ -31,7 +31,7 @@ var (
 	DefaultDirMode   = FileMode(true, true, true)
 
 	FlagIoTempPath    = SystemFlagString(FlagNameIoTempPath, os.TempDir(), "OS root temp dir for creating temporary files")
-	FlagIoFileBackups = SystemFlagInt(FlagNameIoFileBackups, 5, "amount of file backups")
+	FlagIoFileBackups = SystemFlagInt(FlagNameIoFileBackups, 5, "amount of file backups", ValidateRange(0, 100))
 
 	tempDir string
 )
@@ -461,7 +461,9 @@ func FileBackup(filename string) error {
 	}
 
 	filename = CleanPath(filename)
-	mask := filepath.Join(filepath.Dir(filename), FileNamePart(filename)+".*")
+	// only the backups "<filename>.<n>" or "<filename>.bak", but not other files with the same name part
+
+	mask := filepath.Join(filepath.Dir(filename), FileName(filename)+".*")
 
 	files, err := ListFiles(mask, false)
 	if Error(err) {
@@ -132,6 +132,9 @@ func TestFileBackup(t *testing.T) {
 	}()
 
 	filename := filepath.Join(dir, "common.log")
+	sibling := filepath.Join(dir, "common.json")
+
+	require.NoError(t, os.WriteFile(sibling, []byte("{}"), DefaultFileMode))
 
 	for i := range 10 {
 		err := FileBackup(filename)
@@ -149,6 +152,8 @@ func TestFileBackup(t *testing.T) {
 
 	files, err := ListFiles(filename+"*", false)
 	require.Equal(t, len(files), *FlagIoFileBackups+1)
+
+	require.True(t, FileExists(sibling))
 }
 
 func TestListFiles(t *testing.T) {
@@ -1,11 +1,14 @@
 package common
 
 import (
+	"context"
 	"encoding/json"
 	"flag"
 	"fmt"
 	"io"
 	"log"
+	"log/slog"
+	"math"
 	"os"
 	"path/filepath"
 	"slices"
@@ -15,17 +18,43 @@ import (
 )
 
 const (
-	FlagNameLogFileName     = "log.file"
-	FlagNameLogFileSize     = "log.filesize"
-	FlagNameLogVerbose      = "log.verbose"
-	FlagNameLogVerboseError = "log.verbose.error"
-	FlagNameLogIO           = "log.io"
-	FlagNameLogJson         = "log.json"
-	FlagNameLogSys          = "log.sys"
-	FlagNameLogCount        = "log.count"
-	FlagNameLogBreakOnError = "log.breakonerror"
-	FlagNameLogGap          = "log.gap"
-	FlagNameLogEqualError   = "log.equalerror"
+	FlagNameLogFileName       = "log.file"
+	FlagNameLogFileSize       = "log.filesize"
+	FlagNameLogVerbose        = "log.verbose"
+	FlagNameLogVerboseError   = "log.verbose.error"
+	FlagNameLogIO             = "log.io"
+	FlagNameLogJson           = "log.json"
+	FlagNameLogSys            = "log.sys"
+	FlagNameLogCount          = "log.count"
+	FlagNameLogBreakOnError   = "log.breakonerror"
+	FlagNameLogGap            = "log.gap"
+	FlagNameLogEqualError     = "log.equalerror"
+	FlagNameLogLevels         = "log.levels"
+	FlagNameLogRotation       = "log.rotation"
+	FlagNameLogPattern        = "log.pattern"
+	FlagNameLogCompress       = "log.compress"
+	FlagNameLogRetention      = "log.retention"
+	FlagNameLogRetentionAge   = "log.retention.age"
+	FlagNameLogSyslog         = "log.syslog"
+	FlagNameLogSyslogFacility = "log.syslog.facility"
+	FlagNameLogJournald       = "log.journald"
+	FlagNameLogBuffer         = "log.buffer"
+	FlagNameLogOverflow       = "log.overflow"
+	FlagNameLogRateLimit      = "log.ratelimit"
+	FlagNameLogRateWindow     = "log.ratelimit.window"
+)
+
+const (
+	LogRotationSize   = "size"
+	LogRotationHourly = "hourly"
+	LogRotationDaily  = "daily"
+
+	// rotated log files are renamed by the log pattern, default "{name}-{timestamp}{ext}" (e.g. "app-2024-01-01T10-00-00+01-00.log"),
+	// and limited by the log retention (default 5). The former backups "app.log.1", "app.log.2", ... of io.filebackups
+	// are subject to the same retention, so they are deleted after an update once they are the oldest files.
+	LogPatternName      = "{name}"
+	LogPatternExt       = "{ext}"
+	LogPatternTimestamp = "{timestamp}"
 )
 
 const (
@@ -37,25 +66,38 @@ const (
 )
 
 var (
-	FlagLogFileName     = SystemFlagString(FlagNameLogFileName, "", "filename to log file")
-	FlagLogFileSize     = SystemFlagInt(FlagNameLogFileSize, 5*1024*1024, "max log file size")
-	FlagLogVerbose      = flag.Bool(FlagNameLogVerbose, false, "verbose logging")
-	FlagLogVerboseError = SystemFlagBool(FlagNameLogVerboseError, false, "verbose error logging")
-	FlagLogIO           = SystemFlagBool(FlagNameLogIO, false, "trace logging")
-	FlagLogJson         = SystemFlagBool(FlagNameLogJson, false, "JSON output")
-	FlagLogSys          = SystemFlagBool(FlagNameLogSys, false, "Use OS system logger")
-	FlagLogCount        = SystemFlagInt(FlagNameLogCount, 1000, "log count")
-	FlagLogBreakOnError = SystemFlagString(FlagNameLogBreakOnError, "", "break on logging an error")
-	FlagLogGap          = SystemFlagInt(FlagNameLogGap, 100, "time gap after show a separator")
-	FlagLogEqualError   = SystemFlagBool(FlagNameLogEqualError, false, "Log equal (repeated) error")
+	FlagLogFileName       = SystemFlagString(FlagNameLogFileName, "", "filename to log file")
+	FlagLogFileSize       = SystemFlagInt(FlagNameLogFileSize, 5*1024*1024, "max log file size", ValidateRange(1024, math.MaxInt32))
+	FlagLogVerbose        = flag.Bool(FlagNameLogVerbose, false, "verbose logging")
+	FlagLogVerboseError   = SystemFlagBool(FlagNameLogVerboseError, false, "verbose error logging")
+	FlagLogIO             = SystemFlagBool(FlagNameLogIO, false, "trace logging")
+	FlagLogJson           = SystemFlagBool(FlagNameLogJson, false, "JSON output")
+	FlagLogSys            = SystemFlagBool(FlagNameLogSys, false, "Use OS system logger")
+	FlagLogCount          = SystemFlagInt(FlagNameLogCount, 1000, "log count", ValidateRange(0, math.MaxInt32))
+	FlagLogBreakOnError   = SystemFlagString(FlagNameLogBreakOnError, "", "break on logging an error")
+	FlagLogGap            = SystemFlagInt(FlagNameLogGap, 100, "time gap after show a separator", ValidateRange(0, math.MaxInt32))
+	FlagLogEqualError     = SystemFlagBool(FlagNameLogEqualError, false, "Log equal (repeated) error")
+	FlagLogLevels         = SystemFlagString(FlagNameLogLevels, "", "log levels per package (e.g. sqldb=debug,scripting=warn,*=info)", ValidateLogLevels)
+	FlagLogRotation       = SystemFlagString(FlagNameLogRotation, LogRotationSize, fmt.Sprintf("log file rotation additionally to the max log file size (%s)", strings.Join([]string{LogRotationSize, LogRotationHourly, LogRotationDaily}, ",")), ValidateEnum(LogRotationSize, LogRotationHourly, LogRotationDaily))
+	FlagLogPattern        = SystemFlagString(FlagNameLogPattern, LogPatternName+"-"+LogPatternTimestamp+LogPatternExt, fmt.Sprintf("filename pattern of rotated log files (%s)", strings.Join([]string{LogPatternName, LogPatternExt, LogPatternTimestamp}, ",")), ValidateLogPattern)
+	FlagLogCompress       = SystemFlagBool(FlagNameLogCompress, false, "gzip rotated log files")
+	FlagLogRetention      = SystemFlagInt(FlagNameLogRetention, 5, "max count of rotated log files (0 = unlimited)", ValidateRange(0, math.MaxInt32))
+	FlagLogRetentionAge   = SystemFlagString(FlagNameLogRetentionAge, "0", "max age of rotated log files (0 = unlimited)", ValidateDuration())
+	FlagLogSyslog         = SystemFlagString(FlagNameLogSyslog, "", "RFC 5424 syslog server (udp://host:514, tcp://host:601, tls://host:6514)", ValidateSyslogAddress)
+	FlagLogSyslogFacility = SystemFlagInt(FlagNameLogSyslogFacility, 1, "syslog facility (1 = user, 16-23 = local0-local7)", ValidateRange(0, 23))
+	FlagLogJournald       = SystemFlagBool(FlagNameLogJournald, false, "log natively to systemd journald")
+	FlagLogBuffer         = SystemFlagInt(FlagNameLogBuffer, 1000, "count of buffered log entries for the log file and the log sinks", ValidateRange(1, math.MaxInt32))
+	FlagLogRateLimit      = SystemFlagInt(FlagNameLogRateLimit, 0, "max count of log messages per source within the rate limit window (0 = unlimited)", ValidateRange(0, math.MaxInt32))
+	FlagLogRateWindow     = SystemFlagInt(FlagNameLogRateWindow, 60000, "rate limit window in msec", ValidateRange(1, math.MaxInt32))
+	FlagLogOverflow       = SystemFlagString(FlagNameLogOverflow, LogOverflowBlock, fmt.Sprintf("policy on log buffer overflow (%s)", strings.Join([]string{LogOverflowBlock, LogOverflowDrop}, ",")), ValidateEnum(LogOverflowBlock, LogOverflowDrop))
 
 	// synchronizes logging output
 	logMutex    = NewReentrantMutex(true)
 	fw          *fileWriter
 	rw                      = newMemoryWriter()
-	LogDebug    *log.Logger = log.New(rw, prefix(LevelDebug), 0)
-	LogInfo     *log.Logger = log.New(rw, prefix(LevelInfo), 0)
-	LogWarn     *log.Logger = log.New(rw, prefix(LevelWarn), 0)
+	LogDebug    *log.Logger = log.New(io.Discard, prefix(LevelDebug), 0)
+	LogInfo     *log.Logger = log.New(io.Discard, prefix(LevelInfo), 0)
+	LogWarn     *log.Logger = log.New(io.Discard, prefix(LevelWarn), 0)
 	LogError    *log.Logger = log.New(os.Stderr, prefix(LevelError), 0)
 	LogFatal    *log.Logger = log.New(os.Stderr, prefix(LevelFatal), 0)
 	lastLogTime             = time.Now()
@@ -68,11 +110,13 @@ type EventLog struct {
 
 func NewLogEntry(level string, msg string, ri RuntimeInfo) *LogEntry {
 	now := time.Now().UTC()
+	goRoutineId := GoRoutineId()
 
 	return &LogEntry{
 		Time:          now,
 		Timestamp:     now.Format(time.RFC3339),
-		GoRoutineId:   GoRoutineId(),
+		GoRoutineId:   goRoutineId,
+		RequestId:     goRoutineRequestId(goRoutineId),
 		Level:         level,
 		Source:        fmt.Sprintf("%s/%s/%s:%d", ri.Pack, ri.File, ri.Fn, ri.Line),
 		RuntimeInfo:   ri,
@@ -82,15 +126,18 @@ func NewLogEntry(level string, msg string, ri RuntimeInfo) *LogEntry {
 }
 
 type LogEntry struct {
-	Time          time.Time   `json:"-"`
-	Timestamp     string      `json:"timestamp"`
-	GoRoutineId   uint64      `json:"goRoutineId"`
-	Level         string      `json:"level"`
-	Source        string      `json:"source"`
-	RuntimeInfo   RuntimeInfo `json:"runtimeInfo"`
-	Msg           string      `json:"msg"`
-	StacktraceMsg string      `json:"-"`
-	PrintMsg      string      `json:"-"`
+	Time          time.Time       `json:"-"`
+	Timestamp     string          `json:"timestamp"`
+	GoRoutineId   uint64          `json:"goRoutineId"`
+	RequestId     string          `json:"requestId,omitempty"`
+	Level         string          `json:"level"`
+	Source        string          `json:"source"`
+	RuntimeInfo   RuntimeInfo     `json:"runtimeInfo"`
+	Msg           string          `json:"msg"`
+	Attrs         map[string]any  `json:"attrs,omitempty"`
+	StacktraceMsg string          `json:"-"`
+	PrintMsg      string          `json:"-"`
+	Ctx           context.Context `json:"-"`
 }
 
 func init() {
@@ -101,6 +148,14 @@ func init() {
 			*FlagLogSys = false
 		}
 	})
+
+	// only the log flags which are read while holding the log mutex can be changed at runtime
+
+	RegisterReloadableFlags(logMutex, FlagNameLogLevels, FlagNameLogJson, FlagNameLogFileName, FlagNameLogSyslog, FlagNameLogJournald, FlagNameLogBuffer, FlagNameLogOverflow)
+
+	Events.AddListener(EventConfigurationChanged{}, func(event Event) {
+		Error(reconfigureLog(event.(EventConfigurationChanged).Changes))
+	})
 }
 
 func IsLogVerboseEnabled() bool {
@@ -145,22 +200,160 @@ func InitLog() error {
 
 	Error(closeLog())
 
-	writers := []io.Writer{rw}
+	err := SetLogLevels(*FlagLogLevels)
+	if err != nil {
+		return err
+	}
+
+	for _, initFn := range []func() error{initLogFile, initLogSyslog, initLogJournald} {
+		err := initFn()
+		if err != nil {
+			return err
+		}
+	}
+
+	initLoggers()
+
+	isLogInit = true
+
+	if *FlagLogVerbose {
+		for _, line := range GetLogs() {
+			LogDebug.Print(line)
+		}
+	}
+
+	ClearLogs()
+
+	return nil
+}
+
+// reconfigureLog applies the changed log flags to the affected log outputs only,
+// the memory log is neither replayed nor cleared like by InitLog
+func reconfigureLog(changes map[string]FlagChange) error {
+	if !logMutex.TryLock() {
+		return fmt.Errorf("cannot reentrant lock")
+	}
+	defer logMutex.Unlock()
+
+	changed := func(names ...string) bool {
+		for _, name := range names {
+			_, ok := changes[name]
+			if ok {
+				return true
+			}
+		}
+
+		return false
+	}
+
+	if changed(FlagNameLogLevels) {
+		err := SetLogLevels(*FlagLogLevels)
+		if err != nil {
+			return err
+		}
+	}
+
+	if changed(FlagNameLogFileName, FlagNameLogBuffer, FlagNameLogOverflow) {
+		err := closeLogFile()
+		if err != nil {
+			return err
+		}
+
+		err = initLogFile()
+		if err != nil {
+			return err
+		}
+	}
 
-	if IsLogFileEnabled() {
-		err := CheckOutputPath(filepath.Dir(*FlagLogFileName))
+	if changed(FlagNameLogSyslog, FlagNameLogBuffer, FlagNameLogOverflow) {
+		err := closeLogSink(FlagNameLogSyslog)
 		if err != nil {
 			return err
 		}
 
-		fw, err = newFileWriter()
+		err = initLogSyslog()
 		if err != nil {
 			return err
 		}
+	}
+
+	if changed(FlagNameLogJournald, FlagNameLogBuffer, FlagNameLogOverflow) {
+		err := closeLogSink(FlagNameLogJournald)
+		if err != nil {
+			return err
+		}
+
+		err = initLogJournald()
+		if err != nil {
+			return err
+		}
+	}
 
-		writers = append(writers, fw)
+	if changed(FlagNameLogFileName, FlagNameLogBuffer, FlagNameLogOverflow, FlagNameLogJson) {
+		initLoggers()
 	}
 
+	return nil
+}
+
+func initLogFile() error {
+	if !IsLogFileEnabled() {
+		return nil
+	}
+
+	err := CheckOutputPath(filepath.Dir(*FlagLogFileName))
+	if err != nil {
+		return err
+	}
+
+	fw, err = newFileWriter(*FlagLogFileName)
+	if err != nil {
+		return err
+	}
+
+	logSinksMutex.Lock()
+	fwDispatcher = newFileDispatcher(fw)
+	logSinksMutex.Unlock()
+
+	return nil
+}
+
+func initLogSyslog() error {
+	if *FlagLogSyslog == "" {
+		return nil
+	}
+
+	sw, err := newSyslogWriter(*FlagLogSyslog)
+	if err != nil {
+		return err
+	}
+
+	return RegisterLogSink(FlagNameLogSyslog, sw)
+}
+
+func initLogJournald() error {
+	if !*FlagLogJournald {
+		return nil
+	}
+
+	jw, err := newJournaldWriter(journaldSocket)
+	if err != nil {
+		return err
+	}
+
+	return RegisterLogSink(FlagNameLogJournald, jw)
+}
+
+// initLoggers creates the loggers for the console and the log file
+func initLoggers() {
+	var writers []io.Writer
+
+	logSinksMutex.RLock()
+	if fwDispatcher != nil {
+		writers = append(writers, &asyncWriter{dispatcher: fwDispatcher})
+	}
+	logSinksMutex.RUnlock()
+
 	flags := 0
 	if !IsLogJsonEnabled() {
 		flags = log.Lmsgprefix
@@ -173,21 +366,48 @@ func InitLog() error {
 	LogFatal = log.New(MultiWriter(append([]io.Writer{os.Stderr}, writers...)...), "", flags)
 
 	log.SetFlags(flags)
+}
 
-	isLogInit = true
+// shutdownLog writes all buffered log entries and closes the log file and the log sinks
+func shutdownLog() error {
+	if !logMutex.TryLock() {
+		return fmt.Errorf("cannot reentrant lock")
+	}
+	defer logMutex.Unlock()
 
-	if *FlagLogVerbose {
-		for _, line := range GetLogs() {
-			LogDebug.Print(line[len(LevelDebug)+1:])
+	FlushLog()
+
+	return closeLog()
+}
+
+func closeLog() error {
+	for _, name := range []string{FlagNameLogSyslog, FlagNameLogJournald} {
+		err := closeLogSink(name)
+		if err != nil {
+			return err
 		}
 	}
 
-	ClearLogs()
+	return closeLogFile()
+}
+
+func closeLogSink(name string) error {
+	err := UnregisterLogSink(name)
+	if _, ok := err.(*ErrLogSinkNotFound); !ok && err != nil {
+		return err
+	}
 
 	return nil
 }
 
-func closeLog() error {
+func closeLogFile() error {
+	logSinksMutex.Lock()
+	if fwDispatcher != nil {
+		fwDispatcher.close()
+		fwDispatcher = nil
+	}
+	logSinksMutex.Unlock()
+
 	if fw != nil {
 		err := fw.closeFile()
 		if err != nil {
@@ -198,13 +418,34 @@ func closeLog() error {
 	return nil
 }
 
+// formatLog returns nil if the level is not enabled for the package of the caller
 func formatLog(level string, index int, msg string, addStacktrace bool) *LogEntry {
+	return formatLogEntry(NewLogEntry(level, msg, GetRuntimeInfo(index)), addStacktrace)
+}
+
+func formatLogEntry(logEntry *LogEntry, addStacktrace bool) *LogEntry {
+	if !isLogLevelEnabled(logEntry.Level, logEntry.RuntimeInfo.Pack) || !allowLogEntry(logEntry) {
+		return nil
+	}
+
+	return renderLogEntry(logEntry, addStacktrace)
+}
+
+func renderLogEntry(logEntry *LogEntry, addStacktrace bool) *LogEntry {
+	level := logEntry.Level
 	verbose := IsLogVerboseEnabled() || (*FlagLogVerboseError && slices.Contains([]string{LevelError, LevelFatal}, level))
 
-	logEntry := NewLogEntry(level, msg, GetRuntimeInfo(index))
+	msg := logEntry.Msg
+	if logEntry.RequestId != "" {
+		msg = fmt.Sprintf("[%s] %s", logEntry.RequestId, msg)
+	}
+
+	if len(logEntry.Attrs) > 0 {
+		msg = msg + " " + formatLogAttrs(logEntry.Attrs)
+	}
 
 	if addStacktrace || ((level == LevelError || level == LevelFatal) && App() != nil && App().StartFunc != nil && App().StopFunc != nil) {
-		msg = logEntry.StacktraceMsg
+		msg = msg + "\n" + logEntry.RuntimeInfo.Stack
 	}
 
 	// shorten the "source" position only for console log
@@ -238,7 +479,93 @@ func formatLog(level string, index int, msg string, addStacktrace bool) *LogEntr
 	return logEntry
 }
 
-func logDebugPrint(s string) {
+// LogAttrs converts slog like key/value pairs ("key", value, ...) or slog.Attr values to the attributes of a LogEntry.
+// Groups are flattened to "group.key".
+func LogAttrs(keyValues ...any) map[string]any {
+	if len(keyValues) == 0 {
+		return nil
+	}
+
+	record := slog.Record{}
+	record.Add(keyValues...)
+
+	attrs := make(map[string]any)
+
+	record.Attrs(func(attr slog.Attr) bool {
+		addLogAttr(attrs, "", attr)
+
+		return true
+	})
+
+	return attrs
+}
+
+func addLogAttr(attrs map[string]any, group string, attr slog.Attr) {
+	value := attr.Value.Resolve()
+
+	key := attr.Key
+	if group != "" {
+		key = group + "." + key
+	}
+
+	if value.Kind() == slog.KindGroup {
+		for _, ga := range value.Group() {
+			if attr.Key == "" {
+				addLogAttr(attrs, group, ga)
+			} else {
+				addLogAttr(attrs, key, ga)
+			}
+		}
+
+		return
+	}
+
+	if attr.Equal(slog.Attr{}) {
+		return
+	}
+
+	v := value.Any()
+
+	// errors have no exported fields and would be marshalled to JSON as "{}"
+
+	if err, ok := v.(error); ok {
+		v = err.Error()
+	}
+
+	attrs[key] = v
+}
+
+func formatLogAttrs(attrs map[string]any) string {
+	sb := strings.Builder{}
+
+	for _, key := range SortedKeys(attrs) {
+		if sb.Len() > 0 {
+			sb.WriteString(" ")
+		}
+
+		value := fmt.Sprintf("%v", attrs[key])
+		if value == "" || strings.ContainsAny(value, " \t\r\n\"=") {
+			value = strconv.Quote(value)
+		}
+
+		sb.WriteString(fmt.Sprintf("%s=%s", key, value))
+	}
+
+	return sb.String()
+}
+
+// SlogAttrs returns the attributes of the LogEntry sorted by key
+func (logEntry *LogEntry) SlogAttrs() []slog.Attr {
+	var attrs []slog.Attr
+
+	for _, key := range SortedKeys(logEntry.Attrs) {
+		attrs = append(attrs, slog.Any(key, logEntry.Attrs[key]))
+	}
+
+	return attrs
+}
+
+func logDebugPrint(logEntry *LogEntry) {
 	if time.Since(lastLogTime) > MillisecondToDuration(*FlagLogGap) {
 		msg := fmt.Sprintf("time gap [%v]", time.Since(lastLogTime).Truncate(time.Millisecond))
 		msg = fmt.Sprintf("%s %s -", strings.Repeat("-", 120-len(msg)-6-3), msg)
@@ -248,27 +575,42 @@ func logDebugPrint(s string) {
 
 	lastLogTime = time.Now()
 
-	LogDebug.Print(s)
+	LogDebug.Print(logEntry.PrintMsg)
+
+	rw.add(logEntry)
+	writeLogEntry(logEntry)
 }
 
-func logInfoPrint(s string) {
-	LogInfo.Print(s)
+func logInfoPrint(logEntry *LogEntry) {
+	LogInfo.Print(logEntry.PrintMsg)
+
+	rw.add(logEntry)
+	writeLogEntry(logEntry)
 }
 
-func logWarnPrint(s string) {
-	LogWarn.Print(s)
+func logWarnPrint(logEntry *LogEntry) {
+	LogWarn.Print(logEntry.PrintMsg)
+
+	rw.add(logEntry)
+	writeLogEntry(logEntry)
 }
 
-func logErrorPrint(s string) {
-	LogError.Print(s)
+func logErrorPrint(logEntry *LogEntry) {
+	LogError.Print(logEntry.PrintMsg)
+
+	rw.add(logEntry)
+	writeLogEntry(logEntry)
 }
 
-func logFatalPrint(s string) {
-	LogFatal.Print(s)
+func logFatalPrint(logEntry *LogEntry) {
+	LogFatal.Print(logEntry.PrintMsg)
+
+	rw.add(logEntry)
+	writeLogEntry(logEntry)
 }
 
 func Debug(format string, args ...any) {
-	if !IsLogVerboseEnabled() {
+	if !isLogDebugEnabled() {
 		return
 	}
 
@@ -282,12 +624,15 @@ func Debug(format string, args ...any) {
 	}
 
 	logEntry := formatLog(LevelDebug, 2, strings.TrimSpace(format), false)
+	if logEntry == nil {
+		return
+	}
 
-	logDebugPrint(logEntry.PrintMsg)
+	logDebugPrint(logEntry)
 }
 
 func DebugIndex(index int, format string, args ...any) {
-	if !IsLogVerboseEnabled() {
+	if !isLogDebugEnabled() {
 		return
 	}
 
@@ -301,12 +646,15 @@ func DebugIndex(index int, format string, args ...any) {
 	}
 
 	logEntry := formatLog(LevelDebug, 2+index, strings.TrimSpace(format), false)
+	if logEntry == nil {
+		return
+	}
 
-	logDebugPrint(logEntry.PrintMsg)
+	logDebugPrint(logEntry)
 }
 
 func DebugFunc(args ...any) {
-	if !IsLogVerboseEnabled() {
+	if !isLogDebugEnabled() {
 		return
 	}
 
@@ -329,8 +677,11 @@ func DebugFunc(args ...any) {
 	}
 
 	logEntry := formatLog(LevelDebug, 2, str, false)
+	if logEntry == nil {
+		return
+	}
 
-	logDebugPrint(logEntry.PrintMsg)
+	logDebugPrint(logEntry)
 }
 
 func Info(format string, args ...any) {
@@ -344,10 +695,13 @@ func Info(format string, args ...any) {
 	}
 
 	logEntry := formatLog(LevelInfo, 2, strings.TrimSpace(format), false)
+	if logEntry == nil {
+		return
+	}
 
 	Events.Emit(EventLog{Entry: logEntry}, false)
 
-	logInfoPrint(logEntry.PrintMsg)
+	logInfoPrint(logEntry)
 }
 
 func Warn(format string, args ...any) {
@@ -361,10 +715,103 @@ func Warn(format string, args ...any) {
 	}
 
 	logEntry := formatLog(LevelWarn, 2, strings.TrimSpace(format), false)
+	if logEntry == nil {
+		return
+	}
 
 	Events.Emit(EventLog{Entry: logEntry}, false)
 
-	logWarnPrint(logEntry.PrintMsg)
+	logWarnPrint(logEntry)
+}
+
+// logAttrsEntry logs the message with attributes for all levels except FATAL
+func logAttrsEntry(ctx context.Context, level string, ri RuntimeInfo, msg string, attrs map[string]any) {
+	if level == LevelDebug && !isLogDebugEnabled() {
+		return
+	}
+
+	if !logMutex.TryLock() {
+		return
+	}
+	defer logMutex.Unlock()
+
+	logEntry := NewLogEntry(level, strings.TrimSpace(msg), ri)
+	logEntry.Attrs = attrs
+
+	if ctx != nil {
+		logEntry.Ctx = ctx
+
+		if id := RequestIdFromContext(ctx); id != "" {
+			logEntry.RequestId = id
+		}
+	}
+
+	logEntry = formatLogEntry(logEntry, level == LevelError && IsLogVerboseEnabled())
+	if logEntry == nil {
+		return
+	}
+
+	switch level {
+	case LevelDebug:
+		logDebugPrint(logEntry)
+	case LevelInfo:
+		Events.Emit(EventLog{Entry: logEntry}, false)
+
+		logInfoPrint(logEntry)
+	case LevelWarn:
+		Events.Emit(EventLog{Entry: logEntry}, false)
+
+		logWarnPrint(logEntry)
+	default:
+		if isLikeLastError(logEntry) {
+			return
+		}
+
+		Events.Emit(EventLog{Entry: logEntry}, false)
+
+		GoRoutineVars.Get().Set(goVarslastLogEntry, logEntry)
+
+		logErrorPrint(logEntry)
+
+		if *FlagLogBreakOnError != "" && (ToBool(*FlagLogBreakOnError) || strings.Contains(logEntry.PrintMsg, *FlagLogBreakOnError)) {
+			Exit(1)
+		}
+	}
+}
+
+// DebugAttrs logs the message with slog like key/value attributes
+func DebugAttrs(msg string, keyValues ...any) {
+	if !isLogDebugEnabled() {
+		return
+	}
+
+	logAttrsEntry(nil, LevelDebug, GetRuntimeInfo(1), msg, LogAttrs(keyValues...))
+}
+
+// InfoAttrs logs the message with slog like key/value attributes
+func InfoAttrs(msg string, keyValues ...any) {
+	logAttrsEntry(nil, LevelInfo, GetRuntimeInfo(1), msg, LogAttrs(keyValues...))
+}
+
+// WarnAttrs logs the message with slog like key/value attributes
+func WarnAttrs(msg string, keyValues ...any) {
+	logAttrsEntry(nil, LevelWarn, GetRuntimeInfo(1), msg, LogAttrs(keyValues...))
+}
+
+// ErrorAttrs logs the error with slog like key/value attributes
+func ErrorAttrs(err error, keyValues ...any) bool {
+	if err == nil || IsErrExit(err) {
+		return err != nil
+	}
+
+	level := LevelError
+	if IsSuppressedError(err) {
+		level = LevelDebug
+	}
+
+	logAttrsEntry(nil, level, GetRuntimeInfo(1), err.Error(), LogAttrs(keyValues...))
+
+	return true
 }
 
 func TraceError(err error) error {
@@ -378,7 +825,7 @@ func IgnoreError(err error) bool {
 }
 
 func DebugError(err error) bool {
-	if err == nil || !IsLogVerboseEnabled() || IsErrExit(err) {
+	if err == nil || !isLogDebugEnabled() || IsErrExit(err) {
 		return err != nil
 	}
 
@@ -388,20 +835,26 @@ func DebugError(err error) bool {
 	defer logMutex.Unlock()
 
 	logEntry := formatLog(LevelDebug, 2, strings.TrimSpace(err.Error()), IsLogVerboseEnabled())
+	if logEntry == nil {
+		return true
+	}
 
-	logDebugPrint(logEntry.PrintMsg)
+	logDebugPrint(logEntry)
 
 	return true
 }
 
 func asDebugError(index int, err error) bool {
-	if err == nil || !IsLogVerboseEnabled() || IsErrExit(err) {
+	if err == nil || !isLogDebugEnabled() || IsErrExit(err) {
 		return err != nil
 	}
 
 	logEntry := formatLog(LevelDebug, 2+index, strings.TrimSpace(err.Error()), IsLogVerboseEnabled())
+	if logEntry == nil {
+		return true
+	}
 
-	logDebugPrint(logEntry.PrintMsg)
+	logDebugPrint(logEntry)
 
 	return true
 }
@@ -421,10 +874,13 @@ func WarnError(err error) bool {
 	}
 
 	logEntry := formatLog(LevelWarn, 2, strings.TrimSpace(err.Error()), IsLogVerboseEnabled())
+	if logEntry == nil {
+		return true
+	}
 
 	Events.Emit(EventLog{Entry: logEntry}, false)
 
-	logWarnPrint(logEntry.PrintMsg)
+	logWarnPrint(logEntry)
 
 	return true
 }
@@ -470,6 +926,9 @@ func Error(err error) bool {
 	}
 
 	logEntry := formatLog(LevelError, 2, strings.TrimSpace(err.Error()), IsLogVerboseEnabled())
+	if logEntry == nil {
+		return true
+	}
 
 	if isLikeLastError(logEntry) {
 		return true
@@ -479,7 +938,7 @@ func Error(err error) bool {
 
 	GoRoutineVars.Get().Set(goVarslastLogEntry, logEntry)
 
-	logErrorPrint(logEntry.PrintMsg)
+	logErrorPrint(logEntry)
 
 	if *FlagLogBreakOnError != "" && (ToBool(*FlagLogBreakOnError) || strings.Contains(logEntry.PrintMsg, *FlagLogBreakOnError)) {
 		Exit(1)
@@ -503,11 +962,15 @@ func Panic(err error) {
 	Events.Emit(EventLog{Entry: logEntry}, false)
 
 	if isLogInit {
-		logFatalPrint(logEntry.PrintMsg)
+		logFatalPrint(logEntry)
 	} else {
-		logFatalPrint(Capitalize(strings.TrimSpace(err.Error())))
+		logEntry.PrintMsg = Capitalize(strings.TrimSpace(err.Error()))
+
+		logFatalPrint(logEntry)
 	}
 
+	FlushLog()
+
 	Exit(1)
 }
 
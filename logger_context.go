@@ -0,0 +1,150 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync/atomic"
+
+	"github.com/google/uuid"
+)
+
+const (
+	// LogAttrRequestId is the attribute name of the request id for log bridges
+	LogAttrRequestId = "request.id"
+
+	maxRequestIdLen = 128
+)
+
+type requestIdKey struct{}
+
+// goRoutineRequestIds counts the bound request ids, as long as there are none the lookup by goroutine id is skipped
+var goRoutineRequestIds atomic.Int64
+
+// NewRequestId returns a new random request id
+func NewRequestId() string {
+	return uuid.New().String()
+}
+
+// IsValidRequestId checks that a propagated request id is short and printable so that it is safe for logs and headers
+func IsValidRequestId(id string) bool {
+	if id == "" || len(id) > maxRequestIdLen {
+		return false
+	}
+
+	for _, r := range id {
+		if r <= ' ' || r > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// ContextWithRequestId returns a copy of the context which carries the request id
+func ContextWithRequestId(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIdKey{}, id)
+}
+
+// RequestIdFromContext returns the request id of the context or ""
+func RequestIdFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+
+	id, _ := ctx.Value(requestIdKey{}).(string)
+
+	return id
+}
+
+// SetGoRoutineRequestId binds the request id to the current goroutine so that all log entries of the goroutine carry it.
+// The returned func restores the previous request id and must be called.
+// Binding is expensive, code which has a context should prefer the ctx variants like InfoCtx.
+func SetGoRoutineRequestId(id string) func() {
+	goRoutineRequestIds.Add(1)
+
+	goRoutineId := GoRoutineId()
+	prev := goRoutineRequestId(goRoutineId)
+
+	GoRoutineVars.Get().SetById(goRoutineId, goVarsRequestId, id)
+
+	return func() {
+		GoRoutineVars.Get().SetById(goRoutineId, goVarsRequestId, prev)
+
+		goRoutineRequestIds.Add(-1)
+	}
+}
+
+// GoRoutineRequestId returns the request id bound to the current goroutine or ""
+func GoRoutineRequestId() string {
+	if goRoutineRequestIds.Load() == 0 {
+		return ""
+	}
+
+	return goRoutineRequestId(GoRoutineId())
+}
+
+func goRoutineRequestId(goRoutineId uint64) string {
+	if goRoutineRequestIds.Load() == 0 {
+		return ""
+	}
+
+	id, ok := GoRoutineVars.Get().GetById(goRoutineId, goVarsRequestId)
+	if !ok {
+		return ""
+	}
+
+	return id.(string)
+}
+
+// logCtxEntry logs the message with the request id of the context
+func logCtxEntry(ctx context.Context, level string, msg string) {
+	logAttrsEntry(ctx, level, GetRuntimeInfo(2), msg, nil)
+}
+
+// DebugCtx logs the message with the request id of the context
+func DebugCtx(ctx context.Context, format string, args ...any) {
+	if !isLogDebugEnabled() {
+		return
+	}
+
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
+	}
+
+	logCtxEntry(ctx, LevelDebug, format)
+}
+
+// InfoCtx logs the message with the request id of the context
+func InfoCtx(ctx context.Context, format string, args ...any) {
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
+	}
+
+	logCtxEntry(ctx, LevelInfo, format)
+}
+
+// WarnCtx logs the message with the request id of the context
+func WarnCtx(ctx context.Context, format string, args ...any) {
+	if len(args) > 0 {
+		format = fmt.Sprintf(format, args...)
+	}
+
+	logCtxEntry(ctx, LevelWarn, format)
+}
+
+// ErrorCtx logs the error with the request id of the context
+func ErrorCtx(ctx context.Context, err error) bool {
+	if err == nil || IsErrExit(err) {
+		return err != nil
+	}
+
+	level := LevelError
+	if IsSuppressedError(err) {
+		level = LevelDebug
+	}
+
+	logCtxEntry(ctx, level, strings.TrimSpace(err.Error()))
+
+	return true
+}
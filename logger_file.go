@@ -1,34 +1,58 @@
 package common
 
 import (
+	"compress/gzip"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
+	"time"
 )
 
 type fileWriter struct {
 	io.Writer
 
 	mu       sync.Mutex
+	filename string
 	file     *os.File
 	filesize int
+	opened   time.Time
+	now      func() time.Time
 }
 
-func newFileWriter() (*fileWriter, error) {
-	fw := &fileWriter{}
+// ValidateLogPattern is the FlagValidator for the filename pattern of rotated log files
+func ValidateLogPattern(value string) error {
+	if !strings.Contains(value, LogPatternTimestamp) {
+		return fmt.Errorf("missing placeholder %s", LogPatternTimestamp)
+	}
+
+	if strings.ContainsAny(value, "/\\") {
+		return fmt.Errorf("pattern must not contain a path")
+	}
+
+	return nil
+}
+
+func newFileWriter(filename string) (*fileWriter, error) {
+	fw := &fileWriter{
+		filename: filename,
+		now:      time.Now,
+	}
 
-	if FileExists(*FlagLogFileName) {
-		fi, err := os.Stat(*FlagLogFileName)
+	if FileExists(fw.filename) {
+		fi, err := os.Stat(fw.filename)
 		if err != nil {
 			return nil, err
 		}
 
 		fw.filesize = int(fi.Size())
+		fw.opened = fi.ModTime()
 
-		fw.file, err = os.OpenFile(*FlagLogFileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, DefaultFileMode)
+		fw.file, err = os.OpenFile(fw.filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, DefaultFileMode)
 		if err != nil {
 			return nil, err
 		}
@@ -47,11 +71,23 @@ func newFileWriter() (*fileWriter, error) {
 	return fw, nil
 }
 
+// rotationPeriod returns the start of the rotation period of t, zero time for rotation only by size
+func rotationPeriod(t time.Time) time.Time {
+	switch *FlagLogRotation {
+	case LogRotationHourly:
+		return time.Date(t.Year(), t.Month(), t.Day(), t.Hour(), 0, 0, 0, t.Location())
+	case LogRotationDaily:
+		return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	default:
+		return time.Time{}
+	}
+}
+
 func (fw *fileWriter) Write(msg []byte) (int, error) {
 	fw.mu.Lock()
 	defer fw.mu.Unlock()
 
-	if fw.filesize+len(msg) > *FlagLogFileSize {
+	if fw.filesize+len(msg) > *FlagLogFileSize || !rotationPeriod(fw.opened).Equal(rotationPeriod(fw.now())) {
 		err := fw.createFile()
 		if err != nil {
 			return 0, err
@@ -75,22 +111,19 @@ func (fw *fileWriter) Write(msg []byte) (int, error) {
 
 func (fw *fileWriter) createFile() error {
 	if fw.file != nil {
-		err := fw.closeFile()
-		if err != nil {
-			return err
-		}
+		opened := fw.opened
 
-		err = FileBackup(*FlagLogFileName)
+		err := fw.closeFile()
 		if err != nil {
 			return err
 		}
 
-		err = FileDelete(*FlagLogFileName)
+		err = fw.rotateFile(opened)
 		if err != nil {
 			return err
 		}
 	} else {
-		dir := filepath.Dir(*FlagLogFileName)
+		dir := filepath.Dir(fw.filename)
 
 		if !FileExists(dir) {
 			err := os.MkdirAll(dir, DefaultDirMode)
@@ -102,11 +135,207 @@ func (fw *fileWriter) createFile() error {
 
 	var err error
 
-	fw.file, err = os.OpenFile(*FlagLogFileName, os.O_RDWR|os.O_CREATE|os.O_APPEND, DefaultFileMode)
+	fw.file, err = os.OpenFile(fw.filename, os.O_RDWR|os.O_CREATE|os.O_APPEND, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	fw.opened = fw.now()
+
+	return nil
+}
+
+// rotatedFilename returns the filename of the rotated log file by the log pattern, the timestamp is replaced by the replacement
+func (fw *fileWriter) rotatedFilename(replacement string) string {
+	name := *FlagLogPattern
+	name = strings.ReplaceAll(name, LogPatternName, FileNamePart(fw.filename))
+	name = strings.ReplaceAll(name, LogPatternExt, FileNameExt(fw.filename))
+	name = strings.ReplaceAll(name, LogPatternTimestamp, replacement)
+
+	return filepath.Join(filepath.Dir(fw.filename), name)
+}
+
+// rotateFile renames the closed log file by the log pattern, compresses it and applies the retention policy
+func (fw *fileWriter) rotateFile(opened time.Time) error {
+	filename := fw.rotatedFilename(FileTimestamp(opened))
+
+	// multiple rotations by size within the same second
+
+	for i := 1; FileExists(filename) || FileExists(filename+".gz"); i++ {
+		filename = fw.rotatedFilename(FileTimestamp(opened) + "-" + strconv.Itoa(i))
+	}
+
+	err := os.Rename(fw.filename, filename)
+	if err != nil {
+		return err
+	}
+
+	if *FlagLogCompress {
+		err := gzipFile(filename)
+		if err != nil {
+			return err
+		}
+	}
+
+	return fw.applyRetention()
+}
+
+func gzipFile(filename string) error {
+	src, err := os.Open(filename)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		DebugError(src.Close())
+	}()
+
+	dst, err := os.OpenFile(filename+".gz", os.O_WRONLY|os.O_CREATE|os.O_TRUNC, DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	zw := gzip.NewWriter(dst)
+
+	_, err = io.Copy(zw, src)
+	if err == nil {
+		err = zw.Close()
+	}
+
+	if err == nil {
+		err = dst.Close()
+	} else {
+		DebugError(dst.Close())
+	}
+
+	if err != nil {
+		DebugError(FileDelete(filename + ".gz"))
+
+		return err
+	}
+
+	return os.Remove(filename)
+}
+
+// isRotatedFilename returns true if the filename matches the log pattern with a timestamp created by rotateFile
+func (fw *fileWriter) isRotatedFilename(filename string) bool {
+	prefix, suffix, _ := strings.Cut(fw.rotatedFilename("\x00"), "\x00")
+
+	filename = strings.TrimSuffix(filename, ".gz")
+
+	if len(filename) <= len(prefix)+len(suffix) || !strings.HasPrefix(filename, prefix) || !strings.HasSuffix(filename, suffix) {
+		return false
+	}
+
+	timestamp := filename[len(prefix) : len(filename)-len(suffix)]
+
+	_, err := ParseFileTimestamp(timestamp)
+	if err == nil {
+		return true
+	}
+
+	// multiple rotations within the same second have a counter suffix
+
+	p := strings.LastIndex(timestamp, "-")
+	if p == -1 {
+		return false
+	}
+
+	_, err = strconv.Atoi(timestamp[p+1:])
+	if err != nil {
+		return false
+	}
+
+	_, err = ParseFileTimestamp(timestamp[:p])
+
+	return err == nil
+}
+
+// isLegacyBackup returns true for the former log file backups "app.log.1", "app.log.2", ... of io.filebackups
+func (fw *fileWriter) isLegacyBackup(filename string) bool {
+	if !strings.HasPrefix(filename, fw.filename+".") {
+		return false
+	}
+
+	_, err := strconv.Atoi(filename[len(fw.filename)+1:])
+
+	return err == nil
+}
+
+// rotatedFiles returns the rotated log files and the legacy backups, other files with a matching name like "app-server.log" are ignored
+func (fw *fileWriter) rotatedFiles() ([]string, error) {
+	files, err := filepath.Glob(fw.rotatedFilename("*") + "*")
+	if err != nil {
+		return nil, err
+	}
+
+	files = slices.DeleteFunc(files, func(file string) bool {
+		return file == fw.filename || !fw.isRotatedFilename(file)
+	})
+
+	backups, err := filepath.Glob(fw.filename + ".*")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, backup := range backups {
+		if fw.isLegacyBackup(backup) && !slices.Contains(files, backup) {
+			files = append(files, backup)
+		}
+	}
+
+	return files, nil
+}
+
+// applyRetention deletes the oldest rotated log files beyond the max count and the files older than the max age
+func (fw *fileWriter) applyRetention() error {
+	maxAge, err := time.ParseDuration(*FlagLogRetentionAge)
 	if err != nil {
 		return err
 	}
 
+	if *FlagLogRetention == 0 && maxAge == 0 {
+		return nil
+	}
+
+	files, err := fw.rotatedFiles()
+	if err != nil {
+		return err
+	}
+
+	type rotatedFile struct {
+		name    string
+		modTime time.Time
+	}
+
+	var rotatedFiles []rotatedFile
+
+	for _, file := range files {
+		fi, err := os.Stat(file)
+		if err != nil || fi.IsDir() {
+			continue
+		}
+
+		rotatedFiles = append(rotatedFiles, rotatedFile{name: file, modTime: fi.ModTime()})
+	}
+
+	// newest first
+
+	slices.SortFunc(rotatedFiles, func(a, b rotatedFile) int {
+		return b.modTime.Compare(a.modTime)
+	})
+
+	now := fw.now()
+
+	for i, rotatedFile := range rotatedFiles {
+		if (*FlagLogRetention > 0 && i >= *FlagLogRetention) || (maxAge > 0 && now.Sub(rotatedFile.modTime) > maxAge) {
+			err := FileDelete(rotatedFile.name)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	return nil
 }
 
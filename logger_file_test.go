@@ -0,0 +1,180 @@
+package common
+
+import (
+	"compress/gzip"
+	"flag"
+	"github.com/stretchr/testify/require"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func setTestFlag(t *testing.T, name string, value string) {
+	fl := flag.Lookup(name)
+	old := fl.Value.String()
+
+	require.NoError(t, fl.Value.Set(value))
+
+	t.Cleanup(func() {
+		require.NoError(t, fl.Value.Set(old))
+	})
+}
+
+func TestFileWriterRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	setTestFlag(t, FlagNameLogRotation, LogRotationDaily)
+	setTestFlag(t, FlagNameLogCompress, "true")
+	setTestFlag(t, FlagNameLogRetention, "2")
+
+	// files with a similar name are no rotated log files
+
+	siblings := []string{filepath.Join(dir, "app-server.log"), filepath.Join(dir, "app-2024.log"), filepath.Join(dir, "app.log.bak")}
+	for _, sibling := range siblings {
+		require.NoError(t, os.WriteFile(sibling, nil, DefaultFileMode))
+	}
+
+	now := time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local)
+
+	fw := &fileWriter{
+		filename: filepath.Join(dir, "app.log"),
+		now: func() time.Time {
+			return now
+		},
+	}
+
+	require.NoError(t, fw.createFile())
+	defer func() {
+		require.NoError(t, fw.closeFile())
+	}()
+
+	_, err := fw.Write([]byte("day 1\n"))
+	require.NoError(t, err)
+
+	// same day, no rotation
+
+	now = now.Add(time.Hour)
+
+	_, err = fw.Write([]byte("day 1 again\n"))
+	require.NoError(t, err)
+
+	rotated, err := fw.rotatedFiles()
+	require.NoError(t, err)
+	require.Empty(t, rotated)
+
+	// the legacy backups of io.filebackups are subject to the retention
+
+	legacy := []string{filepath.Join(dir, "app.log.1"), filepath.Join(dir, "app.log.2")}
+	for _, backup := range legacy {
+		require.NoError(t, os.WriteFile(backup, nil, DefaultFileMode))
+		require.NoError(t, os.Chtimes(backup, now, now.Add(-time.Hour)))
+	}
+
+	rotated, err = fw.rotatedFiles()
+	require.NoError(t, err)
+	require.ElementsMatch(t, legacy, rotated)
+
+	// next days rotate
+
+	for i := 2; i <= 4; i++ {
+		now = now.Add(24 * time.Hour)
+
+		_, err = fw.Write([]byte("next day\n"))
+		require.NoError(t, err)
+
+		// distinct modification times for the retention order
+
+		rotated, err = fw.rotatedFiles()
+		require.NoError(t, err)
+
+		for _, file := range rotated {
+			fi, err := os.Stat(file)
+			require.NoError(t, err)
+			require.NoError(t, os.Chtimes(file, fi.ModTime(), fi.ModTime().Add(-time.Duration(5-i)*time.Minute)))
+		}
+	}
+
+	rotated, err = fw.rotatedFiles()
+	require.NoError(t, err)
+	require.Len(t, rotated, 2)
+
+	// the oldest rotated file of day 1 is deleted
+
+	first := filepath.Join(dir, "app-"+FileTimestamp(time.Date(2024, 1, 1, 10, 0, 0, 0, time.Local))+".log.gz")
+	require.NotContains(t, rotated, first)
+
+	for _, sibling := range siblings {
+		require.FileExists(t, sibling)
+	}
+
+	for _, backup := range legacy {
+		require.NoFileExists(t, backup)
+	}
+
+	for _, file := range rotated {
+		require.True(t, strings.HasSuffix(file, ".log.gz"))
+
+		f, err := os.Open(file)
+		require.NoError(t, err)
+
+		zr, err := gzip.NewReader(f)
+		require.NoError(t, err)
+
+		ba, err := io.ReadAll(zr)
+		require.NoError(t, err)
+		require.Equal(t, "next day\n", string(ba))
+
+		require.NoError(t, f.Close())
+	}
+}
+
+func TestFileWriterSizeRotation(t *testing.T) {
+	dir := t.TempDir()
+
+	setTestFlag(t, FlagNameLogFileSize, "1024")
+	setTestFlag(t, FlagNameLogRetention, "0")
+
+	fw := &fileWriter{
+		filename: filepath.Join(dir, "app.log"),
+		now:      time.Now,
+	}
+
+	require.NoError(t, fw.createFile())
+	defer func() {
+		require.NoError(t, fw.closeFile())
+	}()
+
+	msg := []byte(strings.Repeat("x", 400) + "\n")
+
+	for i := 0; i < 10; i++ {
+		_, err := fw.Write(msg)
+		require.NoError(t, err)
+	}
+
+	// 2 messages per file, 4 rotations within the same second get a counter suffix
+
+	rotated, err := filepath.Glob(filepath.Join(dir, "app-*.log"))
+	require.NoError(t, err)
+	require.Len(t, rotated, 4)
+
+	require.Error(t, ValidateLogPattern("{name}{ext}"))
+	require.NoError(t, ValidateLogPattern("{name}.{timestamp}{ext}"))
+}
+
+func TestParseFileTimestamp(t *testing.T) {
+	for _, ts := range []time.Time{
+		time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC),
+		time.Date(2024, 1, 1, 10, 0, 0, 0, time.FixedZone("", 3600)),
+		time.Date(2024, 1, 1, 10, 0, 0, 0, time.FixedZone("", -5*3600-1800)),
+	} {
+		parsed, err := ParseFileTimestamp(FileTimestamp(ts))
+		require.NoError(t, err)
+		require.True(t, ts.Equal(parsed))
+	}
+
+	_, err := ParseFileTimestamp("server")
+	require.Error(t, err)
+}
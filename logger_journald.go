@@ -0,0 +1,171 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const (
+	journaldSocket = "/run/systemd/journal/socket"
+	// journaldAttrPrefix is prepended to attribute names which collide with a reserved field
+	journaldAttrPrefix = "ATTR_"
+	// journaldMinValueLen is the minimum length of truncated values of a log entry which exceeds the datagram size
+	journaldMinValueLen = 1024
+)
+
+// journaldReservedFields are written by formatJournald or have a special meaning for journald
+var journaldReservedFields = []string{
+	"MESSAGE", "MESSAGE_ID", "PRIORITY", "SYSLOG_IDENTIFIER", "SYSLOG_FACILITY", "SYSLOG_PID", "SYSLOG_TIMESTAMP", "SYSLOG_RAW",
+	"CODE_FILE", "CODE_LINE", "CODE_FUNC", "ERRNO", "DOCUMENTATION", "TID", "INVOCATION_ID", "USER_INVOCATION_ID", "UNIT", "USER_UNIT",
+	"GOROUTINE_ID", "SOURCE", "REQUEST_ID", "STACKTRACE",
+}
+
+// journaldWriter writes log entries by the native journald protocol so that all fields are queryable by journalctl
+type journaldWriter struct {
+	mu   sync.Mutex
+	conn net.Conn
+}
+
+func newJournaldWriter(socket string) (*journaldWriter, error) {
+	if !IsLinux() {
+		return nil, fmt.Errorf("journald is only available on Linux")
+	}
+
+	conn, err := net.Dial("unixgram", socket)
+	if err != nil {
+		return nil, err
+	}
+
+	return &journaldWriter{
+		conn: conn,
+	}, nil
+}
+
+// journaldFieldName returns a valid journald field name with only uppercase letters, digits and underscores
+func journaldFieldName(s string) string {
+	sb := strings.Builder{}
+
+	for _, r := range strings.ToUpper(s) {
+		if (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			r = '_'
+		}
+
+		sb.WriteRune(r)
+	}
+
+	name := strings.TrimLeft(sb.String(), "_0123456789")
+	if len(name) > 64 {
+		name = name[:64]
+	}
+
+	return name
+}
+
+// journaldAttrName returns the field name of an attribute, names of reserved fields are prefixed so that an attribute
+// cannot override them. Trusted fields starting with "_" cannot be written since leading underscores are removed.
+func journaldAttrName(key string) string {
+	name := journaldFieldName(key)
+	if slices.Contains(journaldReservedFields, name) || strings.HasPrefix(name, "OBJECT_") {
+		name = journaldFieldName(journaldAttrPrefix + name)
+	}
+
+	return name
+}
+
+func appendJournaldField(buf *bytes.Buffer, name string, value string) {
+	if name == "" {
+		return
+	}
+
+	// values with newlines are written with explicit length
+
+	if strings.Contains(value, "\n") {
+		buf.WriteString(name)
+		buf.WriteByte('\n')
+		buf.Write(binary.LittleEndian.AppendUint64(nil, uint64(len(value))))
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+
+		return
+	}
+
+	buf.WriteString(name)
+	buf.WriteByte('=')
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// formatJournald formats the log entry as journald native protocol datagram, maxValueLen > 0 truncates the long values
+func formatJournald(logEntry *LogEntry, identifier string, maxValueLen int) []byte {
+	buf := bytes.Buffer{}
+
+	capValue := func(value string) string {
+		if maxValueLen > 0 {
+			return CapString(value, maxValueLen)
+		}
+
+		return value
+	}
+
+	appendJournaldField(&buf, "MESSAGE", capValue(logEntry.Msg))
+	appendJournaldField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(logEntry.Level)))
+	appendJournaldField(&buf, "SYSLOG_IDENTIFIER", identifier)
+	appendJournaldField(&buf, "CODE_FILE", logEntry.RuntimeInfo.File)
+	appendJournaldField(&buf, "CODE_LINE", strconv.Itoa(logEntry.RuntimeInfo.Line))
+	appendJournaldField(&buf, "CODE_FUNC", logEntry.RuntimeInfo.Fn)
+	appendJournaldField(&buf, "GOROUTINE_ID", strconv.FormatUint(logEntry.GoRoutineId, 10))
+	appendJournaldField(&buf, "SOURCE", logEntry.Source)
+
+	if logEntry.RequestId != "" {
+		appendJournaldField(&buf, "REQUEST_ID", logEntry.RequestId)
+	}
+
+	if (logEntry.Level == LevelError || logEntry.Level == LevelFatal) && logEntry.RuntimeInfo.Stack != "" {
+		appendJournaldField(&buf, "STACKTRACE", capValue(logEntry.RuntimeInfo.Stack))
+	}
+
+	for _, key := range SortedKeys(logEntry.Attrs) {
+		appendJournaldField(&buf, journaldAttrName(key), capValue(fmt.Sprintf("%v", logEntry.Attrs[key])))
+	}
+
+	return buf.Bytes()
+}
+
+func (jw *journaldWriter) WriteEntry(logEntry *LogEntry) error {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+
+	identifier := Title()
+	if identifier == "" {
+		identifier = FileNamePart(os.Args[0])
+	}
+
+	ba := formatJournald(logEntry, identifier, 0)
+
+	// a datagram larger than the socket buffer is rejected, so the long values are truncated until the entry fits
+
+	for maxValueLen := len(ba) / 2; ; maxValueLen /= 2 {
+		_, err := jw.conn.Write(ba)
+		if !errors.Is(err, syscall.EMSGSIZE) || maxValueLen < journaldMinValueLen {
+			return err
+		}
+
+		ba = formatJournald(logEntry, identifier, maxValueLen)
+	}
+}
+
+func (jw *journaldWriter) Close() error {
+	jw.mu.Lock()
+	defer jw.mu.Unlock()
+
+	return jw.conn.Close()
+}
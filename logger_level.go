@@ -0,0 +1,148 @@
+package common
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+	"sync"
+)
+
+// LogLevelDefault is the package name for all packages without an explicit log level
+const LogLevelDefault = "*"
+
+var (
+	logLevels      = make(map[string]string)
+	logLevelsMutex sync.RWMutex
+)
+
+// ParseLogLevels parses a list of package log levels like "sqldb=debug,scripting=warn,*=info"
+func ParseLogLevels(s string) (map[string]string, error) {
+	levels := make(map[string]string)
+
+	for _, item := range Split(s, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		pack, level, ok := strings.Cut(item, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid package log level, expected <package>=<level>: %s", item)
+		}
+
+		pack = strings.TrimSpace(pack)
+		level = strings.ToUpper(strings.TrimSpace(level))
+
+		if pack == "" {
+			return nil, fmt.Errorf("missing package name: %s", item)
+		}
+
+		if LevelToIndex(level) == -1 || level == LevelFatal {
+			return nil, fmt.Errorf("invalid log level %s, expected one of %s", level, strings.Join([]string{LevelDebug, LevelInfo, LevelWarn, LevelError}, ","))
+		}
+
+		levels[pack] = level
+	}
+
+	return levels, nil
+}
+
+// ValidateLogLevels is the FlagValidator for package log levels
+func ValidateLogLevels(value string) error {
+	_, err := ParseLogLevels(value)
+
+	return err
+}
+
+// SetLogLevels replaces all package log levels. InitLog resets them to the "log.levels" flag.
+func SetLogLevels(s string) error {
+	levels, err := ParseLogLevels(s)
+	if err != nil {
+		return err
+	}
+
+	logLevelsMutex.Lock()
+	defer logLevelsMutex.Unlock()
+
+	logLevels = levels
+
+	return nil
+}
+
+// SetLogLevel sets the log level of a package ("*" for the default), an empty level removes the package log level
+func SetLogLevel(pack string, level string) error {
+	logLevelsMutex.Lock()
+	defer logLevelsMutex.Unlock()
+
+	if level == "" {
+		delete(logLevels, pack)
+
+		return nil
+	}
+
+	levels, err := ParseLogLevels(pack + "=" + level)
+	if err != nil {
+		return err
+	}
+
+	logLevels[pack] = levels[pack]
+
+	return nil
+}
+
+// LogLevels returns a copy of all package log levels
+func LogLevels() map[string]string {
+	logLevelsMutex.RLock()
+	defer logLevelsMutex.RUnlock()
+
+	return maps.Clone(logLevels)
+}
+
+// LogLevel returns the effective log level of a package
+func LogLevel(pack string) string {
+	logLevelsMutex.RLock()
+	defer logLevelsMutex.RUnlock()
+
+	level, ok := logLevels[pack]
+	if ok {
+		return level
+	}
+
+	level, ok = logLevels[LogLevelDefault]
+	if ok {
+		return level
+	}
+
+	if IsLogVerboseEnabled() {
+		return LevelDebug
+	}
+
+	return LevelInfo
+}
+
+// isLogLevelEnabled checks the level against the log level of the package, FATAL is always logged
+func isLogLevelEnabled(level string, pack string) bool {
+	if level == LevelFatal {
+		return true
+	}
+
+	return LevelToIndex(level) >= LevelToIndex(LogLevel(pack))
+}
+
+// isLogDebugEnabled returns true if DEBUG could be logged for any package
+func isLogDebugEnabled() bool {
+	if IsLogVerboseEnabled() {
+		return true
+	}
+
+	logLevelsMutex.RLock()
+	defer logLevelsMutex.RUnlock()
+
+	for _, level := range logLevels {
+		if level == LevelDebug {
+			return true
+		}
+	}
+
+	return false
+}
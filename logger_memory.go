@@ -1,44 +1,89 @@
 package common
 
 import (
-	"io"
 	"sync"
 )
 
+// memoryWriter keeps the last log entries up to the "log.count" flag and notifies subscribers about new entries
 type memoryWriter struct {
-	io.Writer
-
-	mu   sync.Mutex
-	msgs []string
+	mu          sync.Mutex
+	entries     []*LogEntry
+	subscribers map[chan *LogEntry]struct{}
 }
 
 func newMemoryWriter() *memoryWriter {
-	return &memoryWriter{}
+	return &memoryWriter{
+		subscribers: make(map[chan *LogEntry]struct{}),
+	}
 }
 
-func (mw *memoryWriter) Write(msg []byte) (int, error) {
+func (mw *memoryWriter) add(logEntry *LogEntry) {
 	mw.mu.Lock()
 	defer mw.mu.Unlock()
 
-	if len(mw.msgs) >= *FlagLogCount {
-		mw.msgs = mw.msgs[len(mw.msgs)-*FlagLogCount:]
+	// flag values are validated only after all flag sources are merged
+
+	count := Max(0, *FlagLogCount)
+
+	if count > 0 {
+		if len(mw.entries) >= count {
+			mw.entries = mw.entries[len(mw.entries)-count+1:]
+		}
+
+		mw.entries = append(mw.entries, logEntry)
 	}
 
-	mw.msgs = append(mw.msgs, string(msg))
+	// slow subscribers miss entries instead of blocking the logging
+
+	for ch := range mw.subscribers {
+		select {
+		case ch <- logEntry:
+		default:
+		}
+	}
+}
+
+// subscribe returns the channel for new entries and the entries logged before, so no entry is missed or duplicated
+func (mw *memoryWriter) subscribe(size int) (chan *LogEntry, []*LogEntry) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	ch := make(chan *LogEntry, size)
+
+	mw.subscribers[ch] = struct{}{}
 
-	return len(msg), nil
+	return ch, append([]*LogEntry{}, mw.entries...)
+}
+
+func (mw *memoryWriter) unsubscribe(ch chan *LogEntry) {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	delete(mw.subscribers, ch)
+}
+
+func (mw *memoryWriter) GetEntries() []*LogEntry {
+	mw.mu.Lock()
+	defer mw.mu.Unlock()
+
+	return append([]*LogEntry{}, mw.entries...)
 }
 
 func (mw *memoryWriter) GetLogs() []string {
 	mw.mu.Lock()
 	defer mw.mu.Unlock()
 
-	return mw.msgs
+	msgs := make([]string, 0, len(mw.entries))
+	for _, entry := range mw.entries {
+		msgs = append(msgs, entry.PrintMsg)
+	}
+
+	return msgs
 }
 
 func (mw *memoryWriter) Clearlogs() {
 	mw.mu.Lock()
 	defer mw.mu.Unlock()
 
-	mw.msgs = nil
+	mw.entries = nil
 }
@@ -0,0 +1,236 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	MimetypeTextEventStream = "text/event-stream"
+)
+
+// LogQuery filters the log entries of the memory log. Zero values do not filter.
+type LogQuery struct {
+	// Level is the minimum level
+	Level       string
+	From        time.Time
+	To          time.Time
+	Packages    []string
+	GoRoutineId uint64
+	RequestId   string
+	Regex       *regexp.Regexp
+	// Limit returns only the last matching entries
+	Limit int
+}
+
+func (query *LogQuery) Match(logEntry *LogEntry) bool {
+	switch {
+	case query.Level != "" && LevelToIndex(logEntry.Level) < LevelToIndex(query.Level):
+		return false
+	case !query.From.IsZero() && logEntry.Time.Before(query.From):
+		return false
+	case !query.To.IsZero() && logEntry.Time.After(query.To):
+		return false
+	case len(query.Packages) > 0 && !slices.Contains(query.Packages, logEntry.RuntimeInfo.Pack):
+		return false
+	case query.GoRoutineId != 0 && logEntry.GoRoutineId != query.GoRoutineId:
+		return false
+	case query.RequestId != "" && logEntry.RequestId != query.RequestId:
+		return false
+	case query.Regex != nil && !query.Regex.MatchString(logEntry.Msg):
+		return false
+	}
+
+	return true
+}
+
+// QueryLogs returns the matching log entries of the memory log
+func QueryLogs(query LogQuery) []*LogEntry {
+	return queryLogEntries(rw.GetEntries(), query)
+}
+
+func queryLogEntries(logEntries []*LogEntry, query LogQuery) []*LogEntry {
+	var entries []*LogEntry
+
+	for _, logEntry := range logEntries {
+		if query.Match(logEntry) {
+			entries = append(entries, logEntry)
+		}
+	}
+
+	if query.Limit > 0 && len(entries) > query.Limit {
+		entries = entries[len(entries)-query.Limit:]
+	}
+
+	return entries
+}
+
+// TailLogs returns a channel with all new log entries of the memory log until the cancel func is called.
+// Entries are dropped if the receiver is too slow.
+func TailLogs(size int) (chan *LogEntry, func()) {
+	ch, _ := rw.subscribe(size)
+
+	return ch, func() {
+		rw.unsubscribe(ch)
+	}
+}
+
+// ParseLogQuery reads the query from the URL parameters "level", "from", "to" (RFC3339), "package" (comma separated), "goroutine", "requestid", "regex" and "limit"
+func ParseLogQuery(r *http.Request) (LogQuery, error) {
+	query := LogQuery{}
+	values := r.URL.Query()
+
+	if v := values.Get("level"); v != "" {
+		query.Level = strings.ToUpper(v)
+
+		if LevelToIndex(query.Level) == -1 {
+			return query, fmt.Errorf("invalid level: %s", v)
+		}
+	}
+
+	for _, p := range []struct {
+		name string
+		t    *time.Time
+	}{
+		{"from", &query.From},
+		{"to", &query.To},
+	} {
+		v := values.Get(p.name)
+		if v == "" {
+			continue
+		}
+
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			return query, fmt.Errorf("invalid %s: %w", p.name, err)
+		}
+
+		*p.t = t
+	}
+
+	if v := values.Get("package"); v != "" {
+		query.Packages = Split(v, ",")
+	}
+
+	if v := values.Get("goroutine"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 64)
+		if err != nil {
+			return query, fmt.Errorf("invalid goroutine: %w", err)
+		}
+
+		query.GoRoutineId = id
+	}
+
+	query.RequestId = values.Get("requestid")
+
+	if v := values.Get("regex"); v != "" {
+		regex, err := regexp.Compile(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid regex: %w", err)
+		}
+
+		query.Regex = regex
+	}
+
+	if v := values.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil {
+			return query, fmt.Errorf("invalid limit: %w", err)
+		}
+
+		query.Limit = limit
+	}
+
+	return query, nil
+}
+
+// LogHandler returns the matching log entries of the memory log as JSON.
+// With "Accept: text/event-stream" the matching entries and all new matching entries are streamed as Server-Sent Events.
+func LogHandler(w http.ResponseWriter, r *http.Request) {
+	DebugFunc()
+
+	query, err := ParseLogQuery(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+
+		return
+	}
+
+	if !strings.Contains(r.Header.Get(ACCEPT), MimetypeTextEventStream) {
+		ba, err := json.MarshalIndent(QueryLogs(query), "", "  ")
+		if Error(err) {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+
+			return
+		}
+
+		Error(HTTPResponse(w, r, http.StatusOK, MimetypeApplicationJson.MimeType, len(ba), strings.NewReader(string(ba))))
+
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming is not supported", http.StatusInternalServerError)
+
+		return
+	}
+
+	// the subscription and the query of the entries logged before are atomic, so no entry is lost or sent twice
+
+	ch, logEntries := rw.subscribe(1000)
+	defer rw.unsubscribe(ch)
+
+	w.Header().Set(CONTENT_TYPE, MimetypeTextEventStream)
+	w.Header().Set("Cache-Control", "no-cache")
+	w.WriteHeader(http.StatusOK)
+
+	sendEvent := func(logEntry *LogEntry) error {
+		ba, err := json.Marshal(logEntry)
+		if err != nil {
+			return err
+		}
+
+		_, err = fmt.Fprintf(w, "data: %s\n\n", ba)
+		if err != nil {
+			return err
+		}
+
+		flusher.Flush()
+
+		return nil
+	}
+
+	for _, logEntry := range queryLogEntries(logEntries, query) {
+		err := sendEvent(logEntry)
+		if DebugError(err) {
+			return
+		}
+	}
+
+	// the limit is only for the entries already logged
+
+	query.Limit = 0
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case logEntry := <-ch:
+			if !query.Match(logEntry) {
+				continue
+			}
+
+			err := sendEvent(logEntry)
+			if DebugError(err) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package common
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	LogAttrSource     = "source"
+	LogAttrSuppressed = "suppressed"
+)
+
+// logRateBucket counts the log entries of one source within the current rate limit window
+type logRateBucket struct {
+	start      time.Time
+	count      int
+	suppressed int
+	ri         RuntimeInfo
+}
+
+var (
+	logRateBuckets = make(map[string]*logRateBucket)
+	// logRatePending are the suppressed counts of already elapsed windows
+	logRatePending = make(map[string]*logRateBucket)
+	logRateMutex   sync.Mutex
+	logRateTimer   *time.Timer
+)
+
+// allowLogEntry checks the log entry against the budget of its source within the rate limit window.
+// DEBUG and FATAL entries are never suppressed.
+func allowLogEntry(logEntry *LogEntry) bool {
+	if *FlagLogRateLimit == 0 || logEntry.Level == LevelDebug || logEntry.Level == LevelFatal {
+		return true
+	}
+
+	logRateMutex.Lock()
+	defer logRateMutex.Unlock()
+
+	window := MillisecondToDuration(*FlagLogRateWindow)
+
+	bucket, ok := logRateBuckets[logEntry.Source]
+	if ok && logEntry.Time.Sub(bucket.start) >= window {
+		if bucket.suppressed > 0 {
+			pending, ok := logRatePending[logEntry.Source]
+			if !ok {
+				pending = &logRateBucket{ri: bucket.ri}
+				logRatePending[logEntry.Source] = pending
+			}
+
+			pending.suppressed += bucket.suppressed
+		}
+
+		ok = false
+	}
+
+	if !ok {
+		bucket = &logRateBucket{
+			start: logEntry.Time,
+			ri:    logEntry.RuntimeInfo,
+		}
+
+		logRateBuckets[logEntry.Source] = bucket
+	}
+
+	if bucket.count < *FlagLogRateLimit {
+		bucket.count++
+
+		return true
+	}
+
+	bucket.suppressed++
+
+	if logRateTimer == nil {
+		logRateTimer = time.AfterFunc(window, logRateSummaries)
+	}
+
+	return false
+}
+
+// logRateSummaries logs a summary for every source with suppressed log entries of an elapsed window
+func logRateSummaries() {
+	// the summaries are taken only while holding the log mutex, so they cannot get lost.
+	// The log mutex is locked first like by the log functions which check the rate limit while holding it.
+
+	if !logMutex.TryLock() {
+		return
+	}
+	defer logMutex.Unlock()
+
+	logRateMutex.Lock()
+
+	now := time.Now().UTC()
+	window := MillisecondToDuration(*FlagLogRateWindow)
+
+	summaries := logRatePending
+	logRatePending = make(map[string]*logRateBucket)
+
+	for source, bucket := range logRateBuckets {
+		if now.Sub(bucket.start) < window {
+			continue
+		}
+
+		if bucket.suppressed > 0 {
+			pending, ok := summaries[source]
+			if !ok {
+				pending = &logRateBucket{ri: bucket.ri}
+				summaries[source] = pending
+			}
+
+			pending.suppressed += bucket.suppressed
+		}
+
+		delete(logRateBuckets, source)
+	}
+
+	// the timer is only needed as long as there are windows which can have suppressed log entries
+
+	logRateTimer = nil
+	for _, bucket := range logRateBuckets {
+		if bucket.suppressed > 0 {
+			logRateTimer = time.AfterFunc(Max(time.Millisecond, window-now.Sub(bucket.start)), logRateSummaries)
+
+			break
+		}
+	}
+
+	logRateMutex.Unlock()
+
+	for _, source := range SortedKeys(summaries) {
+		bucket := summaries[source]
+
+		logEntry := NewLogEntry(LevelWarn, fmt.Sprintf("%d similar messages suppressed", bucket.suppressed), bucket.ri)
+		logEntry.Attrs = map[string]any{
+			LogAttrSource:     source,
+			LogAttrSuppressed: bucket.suppressed,
+		}
+
+		logEntry = renderLogEntry(logEntry, false)
+
+		Events.Emit(EventLog{Entry: logEntry}, false)
+
+		logWarnPrint(logEntry)
+	}
+}
@@ -0,0 +1,281 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"time"
+)
+
+const (
+	LogOverflowBlock = "block"
+	LogOverflowDrop  = "drop"
+)
+
+// LogSink receives all log entries asynchronously by its own buffered dispatcher.
+// A LogSink must not log by itself with the "block" overflow policy.
+type LogSink interface {
+	WriteEntry(logEntry *LogEntry) error
+	Close() error
+}
+
+type ErrLogSinkExists struct {
+	Name string
+}
+
+func (e *ErrLogSinkExists) Error() string {
+	return fmt.Sprintf("log sink already registered: %s", e.Name)
+}
+
+type ErrLogSinkNotFound struct {
+	Name string
+}
+
+func (e *ErrLogSinkNotFound) Error() string {
+	return fmt.Sprintf("log sink not found: %s", e.Name)
+}
+
+type logSink struct {
+	sink       LogSink
+	dispatcher *logDispatcher[*LogEntry]
+}
+
+var (
+	logSinks      = make(map[string]*logSink)
+	logSinksMutex sync.RWMutex
+	fwDispatcher  *logDispatcher[[]byte]
+)
+
+// logDispatcher delivers items by a ring buffer asynchronously to the write func.
+// On overflow the caller is blocked or the oldest item is dropped.
+type logDispatcher[T any] struct {
+	mu      sync.Mutex
+	cond    *sync.Cond
+	ring    []T
+	head    int
+	count   int
+	busy    bool
+	closed  bool
+	block   bool
+	dropped int
+	write   func(T) error
+	onDrop  func(int) error
+	done    chan struct{}
+}
+
+func newLogDispatcher[T any](size int, overflow string, write func(T) error, onDrop func(int) error) *logDispatcher[T] {
+	d := &logDispatcher[T]{
+		ring:   make([]T, Max(1, size)),
+		block:  overflow != LogOverflowDrop,
+		write:  write,
+		onDrop: onDrop,
+		done:   make(chan struct{}),
+	}
+
+	d.cond = sync.NewCond(&d.mu)
+
+	go d.run()
+
+	return d
+}
+
+func (d *logDispatcher[T]) put(item T) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for !d.closed && d.count == len(d.ring) {
+		if !d.block {
+			var zero T
+
+			d.ring[d.head] = zero
+			d.head = (d.head + 1) % len(d.ring)
+			d.count--
+			d.dropped++
+
+			break
+		}
+
+		d.cond.Wait()
+	}
+
+	if d.closed {
+		return
+	}
+
+	d.ring[(d.head+d.count)%len(d.ring)] = item
+	d.count++
+
+	d.cond.Broadcast()
+}
+
+func (d *logDispatcher[T]) run() {
+	defer close(d.done)
+
+	for {
+		d.mu.Lock()
+
+		for d.count == 0 && !d.closed {
+			d.cond.Wait()
+		}
+
+		if d.count == 0 {
+			d.mu.Unlock()
+
+			return
+		}
+
+		var zero T
+
+		item := d.ring[d.head]
+		d.ring[d.head] = zero
+		d.head = (d.head + 1) % len(d.ring)
+		d.count--
+		d.busy = true
+
+		dropped := d.dropped
+		d.dropped = 0
+
+		d.cond.Broadcast()
+		d.mu.Unlock()
+
+		// errors cannot be logged without recursion
+
+		if dropped > 0 && d.onDrop != nil {
+			err := d.onDrop(dropped)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "cannot write log: %v\n", err)
+			}
+		}
+
+		err := d.write(item)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "cannot write log: %v\n", err)
+		}
+
+		d.mu.Lock()
+		d.busy = false
+		d.cond.Broadcast()
+		d.mu.Unlock()
+	}
+}
+
+// flush waits until all buffered items are written
+func (d *logDispatcher[T]) flush() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for d.count > 0 || d.busy {
+		d.cond.Wait()
+	}
+}
+
+// close writes all buffered items and stops the dispatcher
+func (d *logDispatcher[T]) close() {
+	d.mu.Lock()
+	d.closed = true
+	d.cond.Broadcast()
+	d.mu.Unlock()
+
+	<-d.done
+}
+
+func newLogSink(sink LogSink) *logSink {
+	return &logSink{
+		sink: sink,
+		dispatcher: newLogDispatcher(*FlagLogBuffer, *FlagLogOverflow, sink.WriteEntry, func(dropped int) error {
+			return sink.WriteEntry(NewLogEntry(LevelWarn, fmt.Sprintf("%d log entries dropped", dropped), RuntimeInfo{Timestamp: time.Now()}))
+		}),
+	}
+}
+
+// asyncWriter is an io.Writer which writes by a dispatcher
+type asyncWriter struct {
+	dispatcher *logDispatcher[[]byte]
+}
+
+func (aw *asyncWriter) Write(p []byte) (int, error) {
+	// the log.Logger reuses its buffer
+
+	aw.dispatcher.put(append([]byte{}, p...))
+
+	return len(p), nil
+}
+
+func newFileDispatcher(fw *fileWriter) *logDispatcher[[]byte] {
+	return newLogDispatcher(*FlagLogBuffer, *FlagLogOverflow, func(p []byte) error {
+		_, err := fw.Write(p)
+
+		return err
+	}, func(dropped int) error {
+		_, err := fw.Write([]byte(fmt.Sprintf("%d log entries dropped\n", dropped)))
+
+		return err
+	})
+}
+
+// RegisterLogSink registers a sink which receives all log entries from now on
+func RegisterLogSink(name string, sink LogSink) error {
+	logSinksMutex.Lock()
+	defer logSinksMutex.Unlock()
+
+	_, ok := logSinks[name]
+	if ok {
+		return &ErrLogSinkExists{Name: name}
+	}
+
+	logSinks[name] = newLogSink(sink)
+
+	return nil
+}
+
+// UnregisterLogSink writes all buffered log entries to the sink and closes it
+func UnregisterLogSink(name string) error {
+	logSinksMutex.Lock()
+
+	ls, ok := logSinks[name]
+	if ok {
+		delete(logSinks, name)
+	}
+
+	logSinksMutex.Unlock()
+
+	if !ok {
+		return &ErrLogSinkNotFound{Name: name}
+	}
+
+	ls.dispatcher.close()
+
+	return ls.sink.Close()
+}
+
+// LogSinks returns the names of all registered sinks
+func LogSinks() []string {
+	logSinksMutex.RLock()
+	defer logSinksMutex.RUnlock()
+
+	return SortedKeys(logSinks)
+}
+
+// FlushLog waits until the log file and all sinks have written their buffered log entries
+func FlushLog() {
+	logSinksMutex.RLock()
+	defer logSinksMutex.RUnlock()
+
+	if fwDispatcher != nil {
+		fwDispatcher.flush()
+	}
+
+	for _, ls := range logSinks {
+		ls.dispatcher.flush()
+	}
+}
+
+// writeLogEntry dispatches the log entry to all registered sinks
+func writeLogEntry(logEntry *LogEntry) {
+	logSinksMutex.RLock()
+	defer logSinksMutex.RUnlock()
+
+	for _, ls := range logSinks {
+		ls.dispatcher.put(logEntry)
+	}
+}
@@ -0,0 +1,121 @@
+package common
+
+import (
+	"context"
+	"log/slog"
+	"slices"
+)
+
+// SlogHandler is a slog.Handler which logs by the common logger so that slog output of libraries lands in the log files
+type SlogHandler struct {
+	level  slog.Leveler
+	attrs  []slog.Attr
+	groups []string
+}
+
+// NewSlogHandler returns a slog.Handler for the common logger. With a nil level DEBUG is enabled by the "log.verbose" and "log.levels" flags.
+func NewSlogHandler(level slog.Leveler) *SlogHandler {
+	return &SlogHandler{
+		level: level,
+	}
+}
+
+// NewSlogLogger returns a slog.Logger which logs by the common logger
+func NewSlogLogger() *slog.Logger {
+	return slog.New(NewSlogHandler(nil))
+}
+
+func SlogLevelToLevel(level slog.Level) string {
+	switch {
+	case level < slog.LevelInfo:
+		return LevelDebug
+	case level < slog.LevelWarn:
+		return LevelInfo
+	case level < slog.LevelError:
+		return LevelWarn
+	default:
+		return LevelError
+	}
+}
+
+func LevelToSlogLevel(level string) slog.Level {
+	switch level {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelInfo:
+		return slog.LevelInfo
+	case LevelWarn:
+		return slog.LevelWarn
+	default:
+		return slog.LevelError
+	}
+}
+
+func (h *SlogHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	if h.level != nil {
+		return level >= h.level.Level()
+	}
+
+	return level >= slog.LevelInfo || isLogDebugEnabled()
+}
+
+func (h *SlogHandler) Handle(ctx context.Context, record slog.Record) error {
+	attrs := make([]slog.Attr, 0, len(h.attrs)+record.NumAttrs())
+	attrs = append(attrs, h.attrs...)
+
+	groupAttrs := make([]slog.Attr, 0, record.NumAttrs())
+
+	record.Attrs(func(attr slog.Attr) bool {
+		groupAttrs = append(groupAttrs, attr)
+
+		return true
+	})
+
+	attrs = append(attrs, h.groupAttrs(len(h.groups), groupAttrs)...)
+
+	keyValues := make([]any, 0, len(attrs))
+	for _, attr := range attrs {
+		keyValues = append(keyValues, attr)
+	}
+
+	logAttrsEntry(ctx, SlogLevelToLevel(record.Level), GetRuntimeInfoOfPC(record.PC), record.Message, LogAttrs(keyValues...))
+
+	return nil
+}
+
+// groupAttrs nests the attributes into the first count groups of the handler
+func (h *SlogHandler) groupAttrs(count int, attrs []slog.Attr) []slog.Attr {
+	for i := count - 1; i >= 0; i-- {
+		if len(attrs) == 0 {
+			return nil
+		}
+
+		attrs = []slog.Attr{{Key: h.groups[i], Value: slog.GroupValue(attrs...)}}
+	}
+
+	return attrs
+}
+
+func (h *SlogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	if len(attrs) == 0 {
+		return h
+	}
+
+	return &SlogHandler{
+		level:  h.level,
+		attrs:  append(slices.Clone(h.attrs), h.groupAttrs(len(h.groups), attrs)...),
+		groups: h.groups,
+	}
+}
+
+func (h *SlogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+
+	return &SlogHandler{
+		level:  h.level,
+		attrs:  h.attrs,
+		groups: append(slices.Clone(h.groups), name),
+	}
+}
@@ -0,0 +1,237 @@
+package common
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	syslogTimeout = 5 * time.Second
+	// syslogSDID is the structured data id, 32473 is the private enterprise number reserved for documentation
+	syslogSDID = "common@32473"
+)
+
+type syslogWriter struct {
+	mu        sync.Mutex
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	conn      net.Conn
+	hostname  string
+}
+
+// ValidateSyslogAddress is the FlagValidator for a syslog server address
+func ValidateSyslogAddress(value string) error {
+	if value == "" {
+		return nil
+	}
+
+	_, _, err := parseSyslogAddress(value)
+
+	return err
+}
+
+func parseSyslogAddress(value string) (string, string, error) {
+	u, err := url.Parse(value)
+	if err != nil {
+		return "", "", err
+	}
+
+	if !slices.Contains([]string{"udp", "tcp", "tls"}, u.Scheme) {
+		return "", "", fmt.Errorf("unsupported syslog scheme, expected udp, tcp or tls: %s", value)
+	}
+
+	if u.Host == "" || u.Port() == "" {
+		return "", "", fmt.Errorf("missing syslog host and port: %s", value)
+	}
+
+	return u.Scheme, u.Host, nil
+}
+
+func newSyslogWriter(address string) (*syslogWriter, error) {
+	network, host, err := parseSyslogAddress(address)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, err := os.Hostname()
+	if err != nil || hostname == "" {
+		hostname = "-"
+	}
+
+	sw := &syslogWriter{
+		network:  network,
+		address:  host,
+		hostname: hostname,
+	}
+
+	if network == "tls" {
+		sw.tlsConfig, err = NewTlsConfigFromFlags()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err = sw.connect()
+	if err != nil {
+		return nil, err
+	}
+
+	return sw, nil
+}
+
+func (sw *syslogWriter) connect() error {
+	if sw.network == "tls" {
+		conn, err := tls.DialWithDialer(&net.Dialer{Timeout: syslogTimeout}, "tcp", sw.address, sw.tlsConfig)
+		if err != nil {
+			return err
+		}
+
+		sw.conn = conn
+
+		return nil
+	}
+
+	conn, err := net.DialTimeout(sw.network, sw.address, syslogTimeout)
+	if err != nil {
+		return err
+	}
+
+	sw.conn = conn
+
+	return nil
+}
+
+// syslogSeverity maps the log level to the RFC 5424 severity
+func syslogSeverity(level string) int {
+	switch level {
+	case LevelDebug:
+		return 7
+	case LevelInfo:
+		return 6
+	case LevelWarn:
+		return 4
+	case LevelError:
+		return 3
+	default:
+		return 2
+	}
+}
+
+// syslogName returns a RFC 5424 PRINTUSASCII name without the characters "= ]\"" and with max length
+func syslogName(s string, maxLen int) string {
+	sb := strings.Builder{}
+
+	for _, r := range s {
+		if r < 33 || r > 126 || strings.ContainsRune("= ]\"", r) {
+			r = '_'
+		}
+
+		sb.WriteRune(r)
+
+		if sb.Len() == maxLen {
+			break
+		}
+	}
+
+	if sb.Len() == 0 {
+		return "-"
+	}
+
+	return sb.String()
+}
+
+func syslogParamValue(s string) string {
+	return strings.NewReplacer("\\", "\\\\", "\"", "\\\"", "]", "\\]").Replace(s)
+}
+
+// formatSyslog formats the log entry as RFC 5424 message
+func formatSyslog(logEntry *LogEntry, hostname string, appName string) string {
+	sb := strings.Builder{}
+
+	sb.WriteString(fmt.Sprintf("<%d>1 %s %s %s %d - ",
+		*FlagLogSyslogFacility*8+syslogSeverity(logEntry.Level),
+		logEntry.Time.Format(time.RFC3339Nano),
+		syslogName(hostname, 255),
+		syslogName(appName, 48),
+		os.Getpid()))
+
+	sb.WriteString(fmt.Sprintf("[%s goroutine=\"%d\" source=\"%s\"", syslogSDID, logEntry.GoRoutineId, syslogParamValue(logEntry.Source)))
+
+	if logEntry.RequestId != "" {
+		sb.WriteString(fmt.Sprintf(" requestId=\"%s\"", syslogParamValue(logEntry.RequestId)))
+	}
+
+	for _, key := range SortedKeys(logEntry.Attrs) {
+		sb.WriteString(fmt.Sprintf(" %s=\"%s\"", syslogName(key, 32), syslogParamValue(fmt.Sprintf("%v", logEntry.Attrs[key]))))
+	}
+
+	sb.WriteString("] ")
+	sb.WriteString(logEntry.Msg)
+
+	return sb.String()
+}
+
+func (sw *syslogWriter) WriteEntry(logEntry *LogEntry) error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	msg := formatSyslog(logEntry, sw.hostname, Title())
+
+	// stream transports use the octet counting framing of RFC 6587
+
+	if sw.network != "udp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+
+	var err error
+
+	// one reconnect in case the server has closed the connection
+
+	for i := 0; i < 2; i++ {
+		if sw.conn == nil {
+			err = sw.connect()
+			if err != nil {
+				continue
+			}
+		}
+
+		err = sw.conn.SetWriteDeadline(time.Now().Add(syslogTimeout))
+		if err == nil {
+			_, err = sw.conn.Write([]byte(msg))
+		}
+
+		if err == nil {
+			return nil
+		}
+
+		// the error cannot be logged without recursion
+
+		_ = sw.conn.Close()
+		sw.conn = nil
+	}
+
+	return err
+}
+
+func (sw *syslogWriter) Close() error {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+
+	if sw.conn == nil {
+		return nil
+	}
+
+	err := sw.conn.Close()
+
+	sw.conn = nil
+
+	return err
+}
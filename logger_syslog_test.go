@@ -0,0 +1,168 @@
+package common
+
+import (
+	"bufio"
+	"encoding/binary"
+	"github.com/stretchr/testify/require"
+	"net"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func testLogEntry() *LogEntry {
+	logEntry := NewLogEntry(LevelInfo, "hello world", GetRuntimeInfo(0))
+	logEntry.Attrs = LogAttrs("user", "john \"doe\"", "multi line", "a\nb")
+
+	return logEntry
+}
+
+func TestSyslogWriter(t *testing.T) {
+	require.Error(t, ValidateSyslogAddress("http://localhost:514"))
+	require.Error(t, ValidateSyslogAddress("udp://localhost"))
+	require.NoError(t, ValidateSyslogAddress("tls://localhost:6514"))
+
+	// UDP
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, pc.Close())
+	}()
+
+	sw, err := newSyslogWriter("udp://" + pc.LocalAddr().String())
+	require.NoError(t, err)
+
+	require.NoError(t, sw.WriteEntry(testLogEntry()))
+	require.NoError(t, sw.Close())
+
+	ba := make([]byte, 4096)
+
+	n, _, err := pc.ReadFrom(ba)
+	require.NoError(t, err)
+
+	msg := string(ba[:n])
+
+	require.True(t, strings.HasPrefix(msg, "<14>1 "), msg)
+	require.Contains(t, msg, `[common@32473 goroutine="`)
+	require.Contains(t, msg, `multi_line="a`)
+	require.Contains(t, msg, `user="john \"doe\""]`)
+	require.True(t, strings.HasSuffix(msg, "] hello world"), msg)
+
+	// TCP with octet counting
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, listener.Close())
+	}()
+
+	received := make(chan string)
+
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			received <- err.Error()
+
+			return
+		}
+
+		r := bufio.NewReader(conn)
+
+		length, _ := r.ReadString(' ')
+
+		l, _ := strconv.Atoi(strings.TrimSpace(length))
+		ba := make([]byte, l)
+
+		_, _ = r.Read(ba)
+
+		received <- string(ba)
+
+		_ = conn.Close()
+	}()
+
+	sw, err = newSyslogWriter("tcp://" + listener.Addr().String())
+	require.NoError(t, err)
+
+	require.NoError(t, sw.WriteEntry(testLogEntry()))
+
+	msg = <-received
+
+	require.True(t, strings.HasPrefix(msg, "<14>1 "), msg)
+	require.True(t, strings.HasSuffix(msg, "] hello world"), msg)
+
+	require.NoError(t, sw.Close())
+}
+
+func TestJournaldWriter(t *testing.T) {
+	if !IsLinux() {
+		t.Skip()
+	}
+
+	socket := filepath.Join(t.TempDir(), "journal.socket")
+
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, conn.Close())
+	}()
+
+	jw, err := newJournaldWriter(socket)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, jw.Close())
+	}()
+
+	require.NoError(t, jw.WriteEntry(testLogEntry()))
+
+	ba := make([]byte, 64*1024)
+
+	n, err := conn.Read(ba)
+	require.NoError(t, err)
+
+	msg := string(ba[:n])
+
+	require.Contains(t, msg, "MESSAGE=hello world\n")
+	require.Contains(t, msg, "PRIORITY=6\n")
+	require.Contains(t, msg, "CODE_FILE=logger_syslog_test.go\n")
+	require.Contains(t, msg, "USER=john \"doe\"\n")
+	require.Contains(t, msg, "MULTI_LINE\n"+string(binary.LittleEndian.AppendUint64(nil, 3))+"a\nb\n")
+
+	require.Equal(t, "FIELD_1", journaldFieldName("_1field.1"))
+
+	// attributes cannot override reserved fields
+
+	logEntry := testLogEntry()
+	logEntry.Attrs = LogAttrs("message", "attr", "priority", "0", "_pid", "1")
+
+	require.NoError(t, jw.WriteEntry(logEntry))
+
+	n, err = conn.Read(ba)
+	require.NoError(t, err)
+
+	msg = string(ba[:n])
+
+	require.Contains(t, msg, "MESSAGE=hello world\n")
+	require.Contains(t, msg, "ATTR_MESSAGE=attr\n")
+	require.Contains(t, msg, "PRIORITY=6\n")
+	require.Contains(t, msg, "ATTR_PRIORITY=0\n")
+	require.NotContains(t, msg, "_PID=")
+
+	// a message which exceeds the datagram size is truncated
+
+	logEntry = testLogEntry()
+	logEntry.Msg = strings.Repeat("x", 4*1024*1024)
+
+	require.NoError(t, jw.WriteEntry(logEntry))
+
+	ba = make([]byte, 4*1024*1024)
+
+	n, err = conn.Read(ba)
+	require.NoError(t, err)
+
+	msg = string(ba[:n])
+
+	require.Contains(t, msg, "xxx...\n")
+	require.Less(t, n, len(logEntry.Msg))
+}
@@ -0,0 +1,447 @@
+package common
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLogAttrs(t *testing.T) {
+	attrs := LogAttrs("user", "john", slog.Int("count", 3), slog.Group("req", "id", "abc", "path", "/x"), "err", fmt.Errorf("failed"))
+
+	require.Equal(t, map[string]any{
+		"user":     "john",
+		"count":    int64(3),
+		"req.id":   "abc",
+		"req.path": "/x",
+		"err":      "failed",
+	}, attrs)
+
+	require.Equal(t, `count=3 err=failed req.id=abc req.path=/x user=john`, formatLogAttrs(attrs))
+	require.Equal(t, `msg="hello world"`, formatLogAttrs(LogAttrs("msg", "hello world")))
+
+	require.Nil(t, LogAttrs())
+}
+
+func TestSlogHandler(t *testing.T) {
+	var entry *LogEntry
+
+	ef := Events.AddListener(EventLog{}, func(event Event) {
+		entry = event.(EventLog).Entry
+	})
+	defer Events.RemoveListener(ef)
+
+	logger := NewSlogLogger().With("component", "test").WithGroup("req")
+
+	logger.Info("hello", "id", 42)
+
+	require.NotNil(t, entry)
+	require.Equal(t, LevelInfo, entry.Level)
+	require.Equal(t, "hello", entry.Msg)
+	require.Equal(t, map[string]any{
+		"component": "test",
+		"req.id":    int64(42),
+	}, entry.Attrs)
+	require.Equal(t, "common", entry.RuntimeInfo.Pack)
+	require.Equal(t, "logger_test.go", entry.RuntimeInfo.File)
+
+	ba, err := json.Marshal(entry)
+	require.NoError(t, err)
+	require.Contains(t, string(ba), `"attrs":{"component":"test","req.id":42}`)
+
+	entry = nil
+
+	WarnAttrs("careful", "limit", 10)
+
+	require.NotNil(t, entry)
+	require.Equal(t, LevelWarn, entry.Level)
+	require.Equal(t, []slog.Attr{slog.Any("limit", int64(10))}, entry.SlogAttrs())
+}
+
+func TestLogLevels(t *testing.T) {
+	levels, err := ParseLogLevels("sqldb=debug, scripting=Warn,*=info")
+	require.NoError(t, err)
+	require.Equal(t, map[string]string{
+		"sqldb":     LevelDebug,
+		"scripting": LevelWarn,
+		"*":         LevelInfo,
+	}, levels)
+
+	_, err = ParseLogLevels("sqldb")
+	require.Error(t, err)
+
+	_, err = ParseLogLevels("sqldb=verbose")
+	require.Error(t, err)
+
+	_, err = ParseLogLevels("sqldb=fatal")
+	require.Error(t, err)
+
+	old := LogLevels()
+	t.Cleanup(func() {
+		logLevelsMutex.Lock()
+		logLevels = old
+		logLevelsMutex.Unlock()
+	})
+
+	var entry *LogEntry
+
+	ef := Events.AddListener(EventLog{}, func(event Event) {
+		entry = event.(EventLog).Entry
+	})
+	defer Events.RemoveListener(ef)
+
+	require.NoError(t, SetLogLevels("common=warn,*=debug"))
+	require.Equal(t, LevelWarn, LogLevel("common"))
+	require.Equal(t, LevelDebug, LogLevel("sqldb"))
+
+	Info("suppressed")
+	require.Nil(t, entry)
+
+	Warn("logged")
+	require.NotNil(t, entry)
+	require.Equal(t, "logged", entry.Msg)
+
+	entry = nil
+
+	require.NoError(t, SetLogLevel("common", ""))
+	require.NoError(t, SetLogLevel("*", "info"))
+	require.Equal(t, LevelInfo, LogLevel("common"))
+
+	Info("logged")
+	require.NotNil(t, entry)
+
+	require.Error(t, SetLogLevel("common", "unknown"))
+}
+
+type testLogSink struct {
+	mu      sync.Mutex
+	entries []*LogEntry
+	closed  bool
+}
+
+func (sink *testLogSink) WriteEntry(logEntry *LogEntry) error {
+	time.Sleep(time.Millisecond)
+
+	sink.mu.Lock()
+	defer sink.mu.Unlock()
+
+	sink.entries = append(sink.entries, logEntry)
+
+	return nil
+}
+
+func (sink *testLogSink) Close() error {
+	sink.closed = true
+
+	return nil
+}
+
+func TestLogSink(t *testing.T) {
+	sink := &testLogSink{}
+
+	require.NoError(t, RegisterLogSink("test", sink))
+	require.ErrorAs(t, RegisterLogSink("test", sink), new(*ErrLogSinkExists))
+	require.Contains(t, LogSinks(), "test")
+
+	for i := 0; i < 10; i++ {
+		Info("message %d", i)
+	}
+
+	FlushLog()
+
+	var msgs []string
+
+	sink.mu.Lock()
+	for _, entry := range sink.entries {
+		if strings.HasPrefix(entry.Msg, "message ") {
+			msgs = append(msgs, entry.Msg)
+		}
+	}
+	sink.mu.Unlock()
+
+	require.Len(t, msgs, 10)
+	require.Equal(t, "message 9", msgs[9])
+
+	require.NoError(t, UnregisterLogSink("test"))
+	require.True(t, sink.closed)
+	require.ErrorAs(t, UnregisterLogSink("test"), new(*ErrLogSinkNotFound))
+}
+
+func TestReconfigureLog(t *testing.T) {
+	sink := &testLogSink{}
+
+	require.NoError(t, RegisterLogSink("test", sink))
+	defer func() {
+		require.NoError(t, UnregisterLogSink("test"))
+	}()
+
+	filename := filepath.Join(t.TempDir(), "app.log")
+
+	// the cleanups run in reverse order, so the log file is closed after the flag is restored
+
+	t.Cleanup(func() {
+		require.NoError(t, reconfigureLog(map[string]FlagChange{FlagNameLogFileName: {}}))
+		require.False(t, IsLogFileEnabled())
+	})
+	setTestFlag(t, FlagNameLogFileName, filename)
+
+	require.NoError(t, reconfigureLog(map[string]FlagChange{FlagNameLogFileName: {New: filename}}))
+
+	Info("reconfigured")
+
+	FlushLog()
+
+	ba, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.Contains(t, string(ba), "reconfigured")
+
+	// other sinks are not touched
+
+	require.Contains(t, LogSinks(), "test")
+	require.False(t, sink.closed)
+}
+
+func TestLogDispatcherDrop(t *testing.T) {
+	release := make(chan struct{})
+
+	var mu sync.Mutex
+	var written []int
+	dropped := 0
+
+	d := newLogDispatcher(2, LogOverflowDrop, func(i int) error {
+		<-release
+
+		mu.Lock()
+		written = append(written, i)
+		mu.Unlock()
+
+		return nil
+	}, func(n int) error {
+		dropped = n
+
+		return nil
+	})
+
+	// the first item is taken by the dispatcher and blocks, the buffer keeps only the 2 newest items
+
+	d.put(0)
+	require.Eventually(t, func() bool {
+		d.mu.Lock()
+		defer d.mu.Unlock()
+
+		return d.busy
+	}, time.Second, time.Millisecond)
+
+	for i := 1; i <= 5; i++ {
+		d.put(i)
+	}
+
+	close(release)
+
+	d.close()
+
+	require.Equal(t, []int{0, 4, 5}, written)
+	require.Equal(t, 3, dropped)
+}
+
+func TestLogRateLimit(t *testing.T) {
+	setTestFlag(t, FlagNameLogRateLimit, "3")
+	setTestFlag(t, FlagNameLogRateWindow, "100")
+
+	var mu sync.Mutex
+	var entries []*LogEntry
+
+	ef := Events.AddListener(EventLog{}, func(event Event) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		entries = append(entries, event.(EventLog).Entry)
+	})
+	defer Events.RemoveListener(ef)
+
+	for i := 0; i < 10; i++ {
+		Info("flapping %d", i)
+	}
+
+	mu.Lock()
+	require.Len(t, entries, 3)
+	source := entries[0].Source
+	mu.Unlock()
+
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+
+		return len(entries) == 4
+	}, time.Second, time.Millisecond*10)
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	require.Equal(t, LevelWarn, entries[3].Level)
+	require.Equal(t, "7 similar messages suppressed", entries[3].Msg)
+	require.Equal(t, source, entries[3].Source)
+	require.Equal(t, source, entries[3].Attrs[LogAttrSource])
+}
+
+func TestMemoryWriterSubscribe(t *testing.T) {
+	mw := newMemoryWriter()
+
+	// entries with the same timestamp are neither lost nor duplicated
+
+	now := time.Now()
+
+	mw.add(&LogEntry{Time: now, Msg: "before"})
+
+	ch, entries := mw.subscribe(10)
+	defer mw.unsubscribe(ch)
+
+	mw.add(&LogEntry{Time: now, Msg: "after"})
+
+	require.Len(t, entries, 1)
+	require.Equal(t, "before", entries[0].Msg)
+
+	require.Len(t, ch, 1)
+	require.Equal(t, "after", (<-ch).Msg)
+}
+
+func TestQueryLogs(t *testing.T) {
+	start := time.Now().UTC()
+
+	Info("query test alpha")
+	Warn("query test beta")
+	Info("query test gamma")
+
+	entries := QueryLogs(LogQuery{
+		From:  start,
+		Regex: regexp.MustCompile("^query test"),
+	})
+	require.Len(t, entries, 3)
+
+	entries = QueryLogs(LogQuery{
+		Level:    LevelWarn,
+		From:     start,
+		Packages: []string{"common"},
+		Regex:    regexp.MustCompile("^query test"),
+	})
+	require.Len(t, entries, 1)
+	require.Equal(t, "query test beta", entries[0].Msg)
+
+	entries = QueryLogs(LogQuery{
+		From:        start,
+		GoRoutineId: GoRoutineId(),
+		Regex:       regexp.MustCompile("^query test"),
+		Limit:       1,
+	})
+	require.Len(t, entries, 1)
+	require.Equal(t, "query test gamma", entries[0].Msg)
+
+	require.Empty(t, QueryLogs(LogQuery{Packages: []string{"unknown"}}))
+
+	// JSON
+
+	rec := httptest.NewRecorder()
+	LogHandler(rec, httptest.NewRequest(http.MethodGet, "/logs?level=warn&regex=^query+test", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var result []LogEntry
+
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &result))
+	require.NotEmpty(t, result)
+	require.Equal(t, "query test beta", result[len(result)-1].Msg)
+
+	rec = httptest.NewRecorder()
+	LogHandler(rec, httptest.NewRequest(http.MethodGet, "/logs?level=verbose", nil))
+	require.Equal(t, http.StatusBadRequest, rec.Code)
+
+	// Server-Sent Events
+
+	server := httptest.NewServer(http.HandlerFunc(LogHandler))
+	defer server.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, server.URL+"?regex=^tail+test&limit=1", nil)
+	require.NoError(t, err)
+	req.Header.Set(ACCEPT, MimetypeTextEventStream)
+
+	Info("tail test 1")
+
+	resp, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	defer func() {
+		require.NoError(t, resp.Body.Close())
+	}()
+
+	require.Equal(t, MimetypeTextEventStream, resp.Header.Get(CONTENT_TYPE))
+
+	r := bufio.NewReader(resp.Body)
+
+	readEvent := func() *LogEntry {
+		line, err := r.ReadString('\n')
+		require.NoError(t, err)
+		require.True(t, strings.HasPrefix(line, "data: "))
+
+		entry := &LogEntry{}
+		require.NoError(t, json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), entry))
+
+		_, err = r.ReadString('\n')
+		require.NoError(t, err)
+
+		return entry
+	}
+
+	require.Equal(t, "tail test 1", readEvent().Msg)
+
+	Info("other message")
+	Info("tail test 2")
+
+	require.Equal(t, "tail test 2", readEvent().Msg)
+}
+
+func TestLogCtx(t *testing.T) {
+	// unique ids so that the entries of previous runs in the memory log do not match
+
+	ctxId := NewRequestId()
+	ctx := ContextWithRequestId(context.Background(), ctxId)
+
+	InfoCtx(ctx, "ctx test %d", 1)
+	require.True(t, ErrorCtx(ctx, fmt.Errorf("ctx test error")))
+
+	entries := QueryLogs(LogQuery{RequestId: ctxId})
+	require.Len(t, entries, 2)
+	require.Equal(t, "ctx test 1", entries[0].Msg)
+	require.Equal(t, "ctx test error", entries[1].Msg)
+	require.Contains(t, entries[0].PrintMsg, "["+ctxId+"]")
+
+	ba, err := json.Marshal(entries[0])
+	require.NoError(t, err)
+	require.Contains(t, string(ba), `"requestId":"`+ctxId+`"`)
+
+	goRoutineId := NewRequestId()
+	msg := "goroutine test " + goRoutineId
+
+	restore := SetGoRoutineRequestId(goRoutineId)
+	Info(msg)
+	restore()
+	Info(msg)
+
+	entries = QueryLogs(LogQuery{Regex: regexp.MustCompile("^" + msg + "$")})
+	require.Len(t, entries, 2)
+	require.Equal(t, goRoutineId, entries[0].RequestId)
+	require.Equal(t, "", entries[1].RequestId)
+	require.Equal(t, int64(0), goRoutineRequestIds.Load())
+}
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutlog"
 	"go.opentelemetry.io/otel/exporters/stdout/stdoutmetric"
 	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
@@ -70,15 +71,27 @@ func init() {
 
 			eventTelemetry := event.(common.EventTelemetry)
 
+			ctx := eventTelemetry.Ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			var attrs []attribute.KeyValue
+
+			if eventTelemetry.RequestId != "" {
+				attrs = append(attrs, attribute.String(common.LogAttrRequestId, eventTelemetry.RequestId))
+			}
+
 			t := otel.Tracer(common.Title())
 
 			_, span := t.Start(
-				context.Background(),
+				ctx,
 				eventTelemetry.Title,
 				tracer.WithTimestamp(eventTelemetry.Start),
+				tracer.WithAttributes(attrs...),
 			)
 
-			span.End()
+			span.End(tracer.WithTimestamp(eventTelemetry.End))
 
 			return nil
 		}))
@@ -96,19 +109,23 @@ func init() {
 
 			eventLog := event.(common.EventLog)
 
-			switch eventLog.Entry.Level {
-			case common.LevelDebug:
-				Telemetry.Logger.Debug(eventLog.Entry.StacktraceMsg)
-			case common.LevelInfo:
-				Telemetry.Logger.Info(eventLog.Entry.StacktraceMsg)
-			case common.LevelWarn:
-				Telemetry.Logger.Warn(eventLog.Entry.StacktraceMsg)
-			case common.LevelError:
-				Telemetry.Logger.Error(eventLog.Entry.StacktraceMsg)
-			case common.LevelFatal:
-				Telemetry.Logger.Error(fmt.Sprintf("FATAL: %s", eventLog.Entry.StacktraceMsg))
+			msg := eventLog.Entry.StacktraceMsg
+			if eventLog.Entry.Level == common.LevelFatal {
+				msg = fmt.Sprintf("FATAL: %s", msg)
 			}
 
+			ctx := eventLog.Entry.Ctx
+			if ctx == nil {
+				ctx = context.Background()
+			}
+
+			attrs := eventLog.Entry.SlogAttrs()
+			if eventLog.Entry.RequestId != "" {
+				attrs = append(attrs, slog.String(common.LogAttrRequestId, eventLog.Entry.RequestId))
+			}
+
+			Telemetry.Logger.LogAttrs(ctx, common.LevelToSlogLevel(eventLog.Entry.Level), msg, attrs...)
+
 			return nil
 		}))
 	})
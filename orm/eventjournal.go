@@ -0,0 +1,98 @@
+package orm
+
+import (
+	"fmt"
+	"github.com/mpetavy/common"
+	"time"
+)
+
+// EventJournal is the table of the journaled events, the ID is the offset of the record.
+// The IDs of deleted records are never reused, so the offsets stay unique after a compaction.
+type EventJournal struct {
+	ID        int64     `json:"id" gorm:"primaryKey;autoIncrement" desc:"Unique database ID"`
+	CreatedAt time.Time `json:"createdAt" gorm:"index" desc:"Timestamp this DB record has been created"`
+	Type      string    `json:"type" gorm:"index" desc:"Event type name"`
+	Event     string    `json:"event" desc:"Event as JSON"`
+}
+
+// EventJournalStore stores the records of a common.EventJournal in the database
+type EventJournalStore struct {
+	orm *ORM
+}
+
+func NewEventJournalStore(orm *ORM) (*EventJournalStore, error) {
+	common.DebugFunc()
+
+	err := orm.Gorm.AutoMigrate(&EventJournal{})
+	if common.Error(err) {
+		return nil, err
+	}
+
+	return &EventJournalStore{
+		orm: orm,
+	}, nil
+}
+
+func (store *EventJournalStore) Append(record *common.EventJournalRecord) error {
+	row := &EventJournal{
+		CreatedAt: record.Time,
+		Type:      record.Type,
+		Event:     string(record.Event),
+	}
+
+	// offsets start with 0 like the file journal, the autoincrement IDs with 1
+
+	tx := store.orm.Gorm.Create(row)
+	if tx.Error != nil {
+		return tx.Error
+	}
+
+	record.Offset = row.ID - 1
+
+	return nil
+}
+
+func (store *EventJournalStore) Read(from int64, fn func(record *common.EventJournalRecord) error) error {
+	rows, err := store.orm.Gorm.Model(&EventJournal{}).Where("id > ?", from).Order("id").Rows()
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		common.DebugError(rows.Close())
+	}()
+
+	for rows.Next() {
+		row := &EventJournal{}
+
+		err := store.orm.Gorm.ScanRows(rows, row)
+		if err != nil {
+			return err
+		}
+
+		err = fn(&common.EventJournalRecord{
+			Offset: row.ID - 1,
+			Time:   row.CreatedAt.UTC(),
+			Type:   row.Type,
+			Event:  []byte(row.Event),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
+
+func (store *EventJournalStore) Compact(before time.Time) error {
+	tx := store.orm.Gorm.Where("created_at < ?", before).Delete(&EventJournal{})
+	if tx.Error != nil {
+		return fmt.Errorf("cannot compact event journal: %w", tx.Error)
+	}
+
+	return nil
+}
+
+func (store *EventJournalStore) Close() error {
+	return nil
+}
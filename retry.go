@@ -0,0 +1,396 @@
+package common
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type RetryBackoff int
+
+const (
+	// RetryConstant waits InitialDelay between all attempts
+	RetryConstant RetryBackoff = iota
+	// RetryExponential multiplies the delay by Multiplier after each attempt
+	RetryExponential
+	// RetryDecorrelatedJitter waits a random delay between InitialDelay and 3 times the previous delay
+	RetryDecorrelatedJitter
+)
+
+func (b RetryBackoff) String() string {
+	switch b {
+	case RetryExponential:
+		return "exponential"
+	case RetryDecorrelatedJitter:
+		return "decorrelated-jitter"
+	default:
+		return "constant"
+	}
+}
+
+// RetryPolicy runs a func again after a retryable error. A nil policy runs the func only once.
+type RetryPolicy struct {
+	Backoff RetryBackoff
+	// MaxAttempts is the maximum count of calls including the first one, 0 is unlimited
+	MaxAttempts int
+	// MaxDuration stops retrying after the duration since the first call, 0 is unlimited
+	MaxDuration  time.Duration
+	InitialDelay time.Duration
+	// MaxDelay caps the delay between two attempts, 0 is unlimited
+	MaxDelay time.Duration
+	// Multiplier of the exponential backoff, default is 2
+	Multiplier float64
+	// Retryable decides if an error is retried, default is IsErrRetryable
+	Retryable func(err error) bool
+}
+
+type ErrRetryExhausted struct {
+	Attempts int
+	Err      error
+}
+
+func (e *ErrRetryExhausted) Error() string {
+	return fmt.Sprintf("giving up after %d attempts: %v", e.Attempts, e.Err)
+}
+
+func (e *ErrRetryExhausted) Unwrap() error {
+	return e.Err
+}
+
+// NewRetryPolicy creates a policy with the default retryable errors
+func NewRetryPolicy(backoff RetryBackoff, maxAttempts int, initialDelay time.Duration, maxDelay time.Duration) *RetryPolicy {
+	return &RetryPolicy{
+		Backoff:      backoff,
+		MaxAttempts:  maxAttempts,
+		InitialDelay: initialDelay,
+		MaxDelay:     maxDelay,
+	}
+}
+
+// IsErrRetryable returns true for timeouts, network errors and HTTP status codes 429 and 5xx anywhere in the error chain
+func IsErrRetryable(err error) bool {
+	for ; err != nil; err = errors.Unwrap(err) {
+		if IsErrTimeout(err) || IsErrNetOperation(err) {
+			return true
+		}
+
+		if errHTTP, ok := err.(*ErrHTTPRequest); ok {
+			return errHTTP.StatusCode == http.StatusTooManyRequests || errHTTP.StatusCode >= http.StatusInternalServerError
+		}
+	}
+
+	return false
+}
+
+// Delay returns the delay before the next attempt after the previous delay
+func (policy *RetryPolicy) Delay(previous time.Duration) time.Duration {
+	var delay time.Duration
+
+	switch policy.Backoff {
+	case RetryExponential:
+		multiplier := policy.Multiplier
+		if multiplier <= 0 {
+			multiplier = 2
+		}
+
+		delay = policy.InitialDelay
+		if previous > 0 {
+			delay = time.Duration(float64(previous) * multiplier)
+		}
+	case RetryDecorrelatedJitter:
+		upper := Max(policy.InitialDelay, previous*3)
+
+		delay = policy.InitialDelay
+		if upper > policy.InitialDelay {
+			delay += time.Duration(Rnd(int(upper - policy.InitialDelay)))
+		}
+	default:
+		delay = policy.InitialDelay
+	}
+
+	if policy.MaxDelay > 0 {
+		delay = Min(delay, policy.MaxDelay)
+	}
+
+	return delay
+}
+
+func (policy *RetryPolicy) isRetryable(err error) bool {
+	if _, ok := err.(*ErrCircuitOpen); ok {
+		return false
+	}
+
+	if policy.Retryable != nil {
+		return policy.Retryable(err)
+	}
+
+	return IsErrRetryable(err)
+}
+
+// Retry calls fn until it succeeds, the error is not retryable, the policy is exhausted or ctx is done
+func Retry[T any](ctx context.Context, policy *RetryPolicy, fn func(ctx context.Context) (T, error)) (T, error) {
+	if policy == nil {
+		return fn(ctx)
+	}
+
+	start := time.Now()
+	delay := time.Duration(0)
+
+	for attempt := 1; ; attempt++ {
+		value, err := fn(ctx)
+		if err == nil || !policy.isRetryable(err) {
+			return value, err
+		}
+
+		delay = policy.Delay(delay)
+
+		if (policy.MaxAttempts > 0 && attempt >= policy.MaxAttempts) ||
+			(policy.MaxDuration > 0 && time.Since(start)+delay > policy.MaxDuration) {
+			return value, &ErrRetryExhausted{Attempts: attempt, Err: err}
+		}
+
+		Debug("Retry attempt %d after %v: %v", attempt+1, delay, err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return value, errors.Join(err, ctx.Err())
+		case <-AppLifecycle().Channel():
+			return value, err
+		}
+	}
+}
+
+// Do calls fn like Retry
+func (policy *RetryPolicy) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	_, err := Retry(ctx, policy, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+
+	return err
+}
+
+// RetryWithBreaker calls fn by the circuit breaker with the retry policy, both may be nil
+func RetryWithBreaker[T any](ctx context.Context, policy *RetryPolicy, circuitBreaker *CircuitBreaker, fn func(ctx context.Context) (T, error)) (T, error) {
+	return Retry(ctx, policy, func(ctx context.Context) (T, error) {
+		return CircuitExecute(circuitBreaker, func() (T, error) {
+			return fn(ctx)
+		})
+	})
+}
+
+type CircuitState int
+
+const (
+	// CircuitClosed lets all calls pass
+	CircuitClosed CircuitState = iota
+	// CircuitOpen rejects all calls until the open timeout is elapsed
+	CircuitOpen
+	// CircuitHalfOpen lets a single trial call pass, which closes the circuit on success
+	CircuitHalfOpen
+)
+
+func (s CircuitState) String() string {
+	switch s {
+	case CircuitOpen:
+		return "open"
+	case CircuitHalfOpen:
+		return "half-open"
+	default:
+		return "closed"
+	}
+}
+
+type ErrCircuitOpen struct {
+	Name string
+}
+
+func (e *ErrCircuitOpen) Error() string {
+	return fmt.Sprintf("circuit breaker is open: %s", e.Name)
+}
+
+// CircuitBreaker opens after a count of consecutive failures and rejects calls to protect a failing service.
+// A nil breaker lets all calls pass.
+type CircuitBreaker struct {
+	// OnStateChange is called after each state change, with the lock of the breaker held
+	OnStateChange func(name string, from CircuitState, to CircuitState)
+	// IsFailure decides if an error counts as failure, default is every error
+	IsFailure func(err error) bool
+
+	mu               sync.Mutex
+	name             string
+	failureThreshold int
+	openTimeout      time.Duration
+	state            CircuitState
+	failures         int
+	openedAt         time.Time
+	trial            bool
+	// generation is incremented by each state change, so calls which started in a former state are ignored
+	generation uint64
+}
+
+// circuitCall is the state of the breaker at the start of a call
+type circuitCall struct {
+	generation uint64
+	trial      bool
+}
+
+// NewCircuitBreaker creates a breaker which opens after failureThreshold consecutive failures and
+// allows a trial call after openTimeout
+func NewCircuitBreaker(name string, failureThreshold int, openTimeout time.Duration) *CircuitBreaker {
+	return &CircuitBreaker{
+		name:             name,
+		failureThreshold: Max(1, failureThreshold),
+		openTimeout:      openTimeout,
+	}
+}
+
+func (cb *CircuitBreaker) setState(state CircuitState) {
+	if cb.state == state {
+		return
+	}
+
+	Debug("Circuit breaker %s: %s -> %s", cb.name, cb.state, state)
+
+	from := cb.state
+	cb.state = state
+	cb.generation++
+
+	if state == CircuitOpen {
+		cb.openedAt = time.Now()
+	}
+
+	if cb.OnStateChange != nil {
+		cb.OnStateChange(cb.name, from, state)
+	}
+}
+
+// State returns the current state, an open breaker turns half-open after the open timeout
+func (cb *CircuitBreaker) State() CircuitState {
+	if cb == nil {
+		return CircuitClosed
+	}
+
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.checkTimeout()
+
+	return cb.state
+}
+
+func (cb *CircuitBreaker) checkTimeout() {
+	if cb.state == CircuitOpen && time.Since(cb.openedAt) >= cb.openTimeout {
+		cb.setState(CircuitHalfOpen)
+	}
+}
+
+func (cb *CircuitBreaker) allow() (circuitCall, error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	cb.checkTimeout()
+
+	call := circuitCall{generation: cb.generation}
+
+	switch cb.state {
+	case CircuitOpen:
+		return call, &ErrCircuitOpen{Name: cb.name}
+	case CircuitHalfOpen:
+		if cb.trial {
+			return call, &ErrCircuitOpen{Name: cb.name}
+		}
+
+		cb.trial = true
+		call.trial = true
+	}
+
+	return call, nil
+}
+
+func (cb *CircuitBreaker) done(call circuitCall, err error) {
+	cb.mu.Lock()
+	defer cb.mu.Unlock()
+
+	// a call which started before the last state change must not change the state, only the trial call decides
+	// about a half-open breaker
+
+	if call.generation != cb.generation {
+		return
+	}
+
+	failed := err != nil
+	if failed && cb.IsFailure != nil {
+		failed = cb.IsFailure(err)
+	}
+
+	if call.trial {
+		cb.trial = false
+
+		if failed {
+			cb.setState(CircuitOpen)
+		} else {
+			cb.failures = 0
+			cb.setState(CircuitClosed)
+		}
+
+		return
+	}
+
+	if !failed {
+		cb.failures = 0
+
+		return
+	}
+
+	cb.failures++
+
+	if cb.failures >= cb.failureThreshold {
+		cb.failures = 0
+		cb.setState(CircuitOpen)
+	}
+}
+
+// CircuitExecute calls fn if the circuit breaker allows it, otherwise ErrCircuitOpen is returned
+func CircuitExecute[T any](cb *CircuitBreaker, fn func() (T, error)) (T, error) {
+	if cb == nil {
+		return fn()
+	}
+
+	call, err := cb.allow()
+	if err != nil {
+		var zero T
+
+		return zero, err
+	}
+
+	// a panic counts as failure so that a half-open breaker does not stay blocked by its trial call
+
+	finished := false
+
+	defer func() {
+		if !finished {
+			cb.done(call, fmt.Errorf("panic"))
+		}
+	}()
+
+	value, err := fn()
+
+	finished = true
+
+	cb.done(call, err)
+
+	return value, err
+}
+
+// Do calls fn like CircuitExecute
+func (cb *CircuitBreaker) Do(fn func() error) error {
+	_, err := CircuitExecute(cb, func() (struct{}, error) {
+		return struct{}{}, fn()
+	})
+
+	return err
+}
@@ -0,0 +1,276 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetryPolicyDelay(t *testing.T) {
+	policy := NewRetryPolicy(RetryConstant, 0, 10*time.Millisecond, 0)
+	require.Equal(t, 10*time.Millisecond, policy.Delay(0))
+	require.Equal(t, 10*time.Millisecond, policy.Delay(10*time.Millisecond))
+
+	policy = NewRetryPolicy(RetryExponential, 0, 10*time.Millisecond, 50*time.Millisecond)
+
+	var delays []time.Duration
+
+	delay := time.Duration(0)
+	for range 4 {
+		delay = policy.Delay(delay)
+		delays = append(delays, delay)
+	}
+
+	require.Equal(t, []time.Duration{10 * time.Millisecond, 20 * time.Millisecond, 40 * time.Millisecond, 50 * time.Millisecond}, delays)
+
+	policy = NewRetryPolicy(RetryDecorrelatedJitter, 0, 10*time.Millisecond, 100*time.Millisecond)
+
+	delay = 0
+	for range 20 {
+		previous := delay
+
+		delay = policy.Delay(previous)
+
+		require.GreaterOrEqual(t, delay, 10*time.Millisecond)
+		require.LessOrEqual(t, delay, Min(100*time.Millisecond, Max(10*time.Millisecond, previous*3)))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	errTimeout := &ErrTimeout{Duration: time.Millisecond}
+
+	tests := []struct {
+		name     string
+		failures int
+		err      error
+		attempts int
+		wantErr  bool
+	}{
+		{"success", 0, errTimeout, 1, false},
+		{"recovered", 2, errTimeout, 3, false},
+		{"exhausted", 10, errTimeout, 3, true},
+		{"not retryable", 10, fmt.Errorf("invalid"), 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			policy := NewRetryPolicy(RetryExponential, 3, time.Millisecond, 0)
+
+			attempts := 0
+
+			value, err := Retry(context.Background(), policy, func(ctx context.Context) (int, error) {
+				attempts++
+
+				if attempts <= tt.failures {
+					return 0, tt.err
+				}
+
+				return attempts, nil
+			})
+
+			require.Equal(t, tt.attempts, attempts)
+
+			if tt.wantErr {
+				require.ErrorIs(t, err, tt.err)
+			} else {
+				require.NoError(t, err)
+				require.Equal(t, attempts, value)
+			}
+		})
+	}
+
+	var errExhausted *ErrRetryExhausted
+
+	_, err := Retry(context.Background(), NewRetryPolicy(RetryConstant, 2, time.Millisecond, 0), func(ctx context.Context) (int, error) {
+		return 0, errTimeout
+	})
+	require.ErrorAs(t, err, &errExhausted)
+	require.Equal(t, 2, errExhausted.Attempts)
+}
+
+func TestCircuitBreaker(t *testing.T) {
+	var transitions []string
+
+	cb := NewCircuitBreaker("test", 2, 50*time.Millisecond)
+	cb.OnStateChange = func(name string, from CircuitState, to CircuitState) {
+		transitions = append(transitions, fmt.Sprintf("%s->%s", from, to))
+	}
+
+	fail := func() error {
+		return fmt.Errorf("failed")
+	}
+	succeed := func() error {
+		return nil
+	}
+
+	require.Error(t, cb.Do(fail))
+	require.NoError(t, cb.Do(succeed))
+	require.Error(t, cb.Do(fail))
+	require.Equal(t, CircuitClosed, cb.State())
+	require.Error(t, cb.Do(fail))
+	require.Equal(t, CircuitOpen, cb.State())
+
+	var errOpen *ErrCircuitOpen
+	require.ErrorAs(t, cb.Do(succeed), &errOpen)
+
+	time.Sleep(60 * time.Millisecond)
+
+	require.Equal(t, CircuitHalfOpen, cb.State())
+	require.Error(t, cb.Do(fail))
+	require.Equal(t, CircuitOpen, cb.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	require.NoError(t, cb.Do(succeed))
+	require.Equal(t, CircuitClosed, cb.State())
+
+	require.Equal(t, []string{"closed->open", "open->half-open", "half-open->open", "open->half-open", "half-open->closed"}, transitions)
+
+	// an open breaker stops retrying
+
+	cb = NewCircuitBreaker("test", 1, time.Minute)
+
+	attempts := 0
+
+	_, err := RetryWithBreaker(context.Background(), NewRetryPolicy(RetryConstant, 5, time.Millisecond, 0), cb, func(ctx context.Context) (int, error) {
+		attempts++
+
+		return 0, &ErrTimeout{}
+	})
+	require.ErrorAs(t, err, &errOpen)
+	require.Equal(t, 1, attempts)
+}
+
+func TestCircuitBreakerTrial(t *testing.T) {
+	cb := NewCircuitBreaker("test", 1, 50*time.Millisecond)
+
+	release := make(chan error)
+	started := make(chan struct{})
+	done := make(chan struct{})
+
+	call := func() {
+		defer func() {
+			done <- struct{}{}
+		}()
+
+		_ = cb.Do(func() error {
+			started <- struct{}{}
+
+			return <-release
+		})
+	}
+
+	// a slow call starts while the breaker is closed
+
+	go call()
+	<-started
+
+	require.Error(t, cb.Do(func() error {
+		return fmt.Errorf("failed")
+	}))
+	require.Equal(t, CircuitOpen, cb.State())
+
+	time.Sleep(60 * time.Millisecond)
+
+	// the trial call of the half-open breaker
+
+	go call()
+	<-started
+
+	// the slow call neither closes the breaker nor releases the trial
+
+	release <- nil
+	<-done
+
+	require.Equal(t, CircuitHalfOpen, cb.State())
+
+	var errOpen *ErrCircuitOpen
+	require.ErrorAs(t, cb.Do(func() error {
+		return nil
+	}), &errOpen)
+
+	// only the trial call decides
+
+	release <- fmt.Errorf("failed")
+	<-done
+
+	require.Equal(t, CircuitOpen, cb.State())
+}
+
+func TestHTTPRequestWithRetry(t *testing.T) {
+	var calls atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ba, err := ReadBody(r.Body)
+		require.NoError(t, err)
+		require.Equal(t, "payload", string(ba))
+
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	policy := NewRetryPolicy(RetryExponential, 3, time.Millisecond, 0)
+
+	_, _, err := HTTPRequestWithRetry(context.Background(), policy, nil, nil, time.Second, http.MethodPost, server.URL, nil, nil, "", "", strings.NewReader("payload"), http.StatusOK)
+	require.NoError(t, err)
+	require.Equal(t, int32(3), calls.Load())
+
+	// the retries stop when the context is done
+
+	calls.Store(0)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, _, err = HTTPRequestWithRetry(ctx, NewRetryPolicy(RetryConstant, 0, time.Minute, 0), nil, nil, time.Second, http.MethodPost, server.URL, nil, nil, "", "", strings.NewReader("payload"), http.StatusOK)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	require.Equal(t, int32(1), calls.Load())
+}
+
+func TestNetworkClientRetry(t *testing.T) {
+	// a closed listener gives a free port which refuses connections
+
+	listener, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+
+	address := listener.Addr().String()
+
+	require.NoError(t, listener.Close())
+
+	networkClient, err := NewNetworkClient(address, nil)
+	require.NoError(t, err)
+
+	networkClient.RetryPolicy = NewRetryPolicy(RetryConstant, 3, time.Millisecond, 0)
+	networkClient.CircuitBreaker = NewCircuitBreaker(address, 3, time.Minute)
+
+	_, err = networkClient.Connect()
+
+	var errExhausted *ErrRetryExhausted
+	require.ErrorAs(t, err, &errExhausted)
+	require.Equal(t, 3, errExhausted.Attempts)
+	require.Equal(t, CircuitOpen, networkClient.CircuitBreaker.State())
+
+	// the retries stop when the context is done
+
+	networkClient.RetryPolicy = NewRetryPolicy(RetryConstant, 0, time.Minute, 0)
+	networkClient.CircuitBreaker = nil
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = networkClient.ConnectContext(ctx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+}
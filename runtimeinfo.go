@@ -126,9 +126,25 @@ func GetRuntimeInfo(pos int) RuntimeInfo {
 		}
 	}
 
-	f := runtime.FuncForPC(pc)
+	return newRuntimeInfo(runtime.FuncForPC(pc).Name(), file, line, stack)
+}
+
+// GetRuntimeInfoOfPC returns the runtime info of a program counter (e.g. slog.Record.PC) without stacktrace
+func GetRuntimeInfoOfPC(pc uintptr) RuntimeInfo {
+	if pc == 0 {
+		return RuntimeInfo{UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, 0, time.Time{}}
+	}
+
+	frame, _ := runtime.CallersFrames([]uintptr{pc}).Next()
+	if frame.Function == "" {
+		return RuntimeInfo{UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, UNKNOWN, 0, time.Time{}}
+	}
+
+	return newRuntimeInfo(frame.Function, frame.File, frame.Line, "")
+}
 
-	fn := f.Name()
+func newRuntimeInfo(fnName string, file string, line int, stack string) RuntimeInfo {
+	fn := fnName
 
 	if strings.Contains(fn, "/") {
 		fn = fn[strings.LastIndex(fn, "/")+1:]
@@ -141,7 +157,7 @@ func GetRuntimeInfo(pos int) RuntimeInfo {
 
 	file = path.Base(file)
 
-	pack := f.Name()
+	pack := fnName
 	pack = pack[strings.LastIndex(pack, "/")+1:]
 	pack = pack[0:strings.Index(pack, ".")]
 
@@ -0,0 +1,466 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+const (
+	schedulerHistoryLen = 100
+	// schedulerMaxDue limits the count of due activations which are evaluated after a long delay of the scheduler
+	schedulerMaxDue = 1000
+)
+
+type OverlapPolicy int
+
+const (
+	// OverlapSkip skips an activation while the previous run of the job is still running
+	OverlapSkip OverlapPolicy = iota
+	// OverlapQueue runs an activation after the previous run of the job is finished
+	OverlapQueue
+	// OverlapParallel runs an activation concurrently to the previous runs of the job
+	OverlapParallel
+)
+
+func (p OverlapPolicy) String() string {
+	switch p {
+	case OverlapQueue:
+		return "queue"
+	case OverlapParallel:
+		return "parallel"
+	default:
+		return "skip"
+	}
+}
+
+type JobStatus string
+
+const (
+	JobOk      JobStatus = "ok"
+	JobFailed  JobStatus = "failed"
+	JobSkipped JobStatus = "skipped"
+	JobMissed  JobStatus = "missed"
+)
+
+// JobFunc runs a job for its scheduled activation time. The context is canceled when the scheduler stops.
+type JobFunc func(ctx context.Context, scheduled time.Time) error
+
+// JobOptions configures a job. Zero values are the defaults.
+type JobOptions struct {
+	Overlap OverlapPolicy
+	// CatchUp is the maximum count of missed activations which are run additionally to the latest one after the scheduler
+	// was delayed, e.g. by a suspended system or a restart with LastRun. Other missed activations are recorded as missed.
+	CatchUp int
+	// LastRun is the time of the last run before a restart, the activations since then are due
+	LastRun time.Time
+}
+
+// JobRun is an entry in the history of the scheduler
+type JobRun struct {
+	Job       string
+	Scheduled time.Time
+	Start     time.Time
+	End       time.Time
+	Status    JobStatus
+	Err       error
+}
+
+type ErrSchedulerJob struct {
+	Name string
+}
+
+func (e *ErrSchedulerJob) Error() string {
+	return fmt.Sprintf("job already scheduled: %s", e.Name)
+}
+
+type scheduledJob struct {
+	name     string
+	schedule Schedule
+	options  JobOptions
+	fn       JobFunc
+	next     time.Time
+	running  int
+	pending  [][]time.Time
+}
+
+// Scheduler runs jobs at cron expressions or fixed intervals. The jobs are stopped on EventShutdown.
+type Scheduler struct {
+	mu           sync.Mutex
+	loc          *time.Location
+	jobs         map[string]*scheduledJob
+	history      []JobRun
+	task         *BackgroundTask
+	wakeCh       chan struct{}
+	ctx          context.Context
+	cancel       context.CancelFunc
+	wg           sync.WaitGroup
+	started      bool
+	eventManager *EventManager
+	subscription *Subscription
+}
+
+// NewScheduler creates a scheduler for the cron expressions in loc, a nil loc is the app timezone (app.timezone)
+func NewScheduler(loc *time.Location) *Scheduler {
+	scheduler := &Scheduler{
+		loc:          loc,
+		jobs:         make(map[string]*scheduledJob),
+		wakeCh:       make(chan struct{}, 1),
+		eventManager: Events,
+	}
+
+	scheduler.task = NewBackgroundTask(scheduler.run)
+
+	return scheduler
+}
+
+// AddJob schedules fn by a cron expression or an interval, see ParseSchedule
+func (scheduler *Scheduler) AddJob(name string, spec string, options JobOptions, fn JobFunc) error {
+	DebugFunc("%s: %s", name, spec)
+
+	schedule, err := ParseSchedule(spec, scheduler.loc)
+	if Error(err) {
+		return err
+	}
+
+	return scheduler.AddScheduleJob(name, schedule, options, fn)
+}
+
+// AddIntervalJob schedules fn at multiples of the interval since the start of the day in the location of the scheduler
+func (scheduler *Scheduler) AddIntervalJob(name string, interval time.Duration, options JobOptions, fn JobFunc) error {
+	return scheduler.AddScheduleJob(name, &IntervalSchedule{Interval: interval, Loc: scheduler.loc}, options, fn)
+}
+
+// AddScheduleJob schedules fn by the schedule
+func (scheduler *Scheduler) AddScheduleJob(name string, schedule Schedule, options JobOptions, fn JobFunc) error {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	_, ok := scheduler.jobs[name]
+	if ok {
+		return &ErrSchedulerJob{Name: name}
+	}
+
+	from := options.LastRun
+	if from.IsZero() {
+		from = time.Now()
+	}
+
+	scheduler.jobs[name] = &scheduledJob{
+		name:     name,
+		schedule: schedule,
+		options:  options,
+		fn:       fn,
+		next:     schedule.Next(from),
+	}
+
+	scheduler.wake()
+
+	return nil
+}
+
+// RemoveJob removes the job, a running job is not interrupted
+func (scheduler *Scheduler) RemoveJob(name string) error {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	_, ok := scheduler.jobs[name]
+	if !ok {
+		return &ErrNotFound[string]{What: name}
+	}
+
+	delete(scheduler.jobs, name)
+
+	return nil
+}
+
+// Next returns the next activation of the job
+func (scheduler *Scheduler) Next(name string) (time.Time, error) {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	job, ok := scheduler.jobs[name]
+	if !ok {
+		return time.Time{}, &ErrNotFound[string]{What: name}
+	}
+
+	return job.next, nil
+}
+
+// Start runs the scheduler until Stop or EventShutdown
+func (scheduler *Scheduler) Start() {
+	DebugFunc()
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	if scheduler.started {
+		return
+	}
+
+	scheduler.started = true
+	scheduler.ctx, scheduler.cancel = context.WithCancel(context.Background())
+
+	scheduler.subscription = Subscribe(scheduler.eventManager, 0, func(event EventShutdown) error {
+		scheduler.Stop()
+
+		return nil
+	})
+
+	scheduler.task.Start()
+}
+
+// Stop stops the scheduler, cancels the context of the running jobs and waits for them
+func (scheduler *Scheduler) Stop() {
+	DebugFunc()
+
+	scheduler.mu.Lock()
+
+	if !scheduler.started {
+		scheduler.mu.Unlock()
+
+		return
+	}
+
+	scheduler.started = false
+
+	subscription := scheduler.subscription
+	scheduler.subscription = nil
+
+	scheduler.cancel()
+
+	scheduler.mu.Unlock()
+
+	subscription.Unsubscribe()
+
+	// the scheduler loop needs the lock, so it is stopped without holding it
+
+	scheduler.task.Stop(true)
+	scheduler.wg.Wait()
+}
+
+func (scheduler *Scheduler) wake() {
+	select {
+	case scheduler.wakeCh <- struct{}{}:
+	default:
+	}
+}
+
+func (scheduler *Scheduler) run(task *BackgroundTask) {
+	for {
+		scheduler.mu.Lock()
+
+		next := time.Time{}
+
+		for _, job := range scheduler.jobs {
+			scheduler.dispatchDue(job, time.Now())
+
+			if !job.next.IsZero() && (next.IsZero() || job.next.Before(next)) {
+				next = job.next
+			}
+		}
+
+		scheduler.mu.Unlock()
+
+		// a timer delayed by a suspended system is handled by the catch-up of the missed activations
+
+		var timer *time.Timer
+		var timerCh <-chan time.Time
+
+		if !next.IsZero() {
+			timer = time.NewTimer(time.Until(next))
+			timerCh = timer.C
+		}
+
+		select {
+		case <-timerCh:
+		case <-scheduler.wakeCh:
+		case <-task.Channel():
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+
+		if !task.IsAlive() {
+			return
+		}
+	}
+}
+
+// dispatchDue runs all due activations of the job, the caller must hold the lock
+func (scheduler *Scheduler) dispatchDue(job *scheduledJob, now time.Time) {
+	var due []time.Time
+	var firstMissed time.Time
+
+	missed := 0
+
+	t := job.next
+
+	for !t.IsZero() && !t.After(now) {
+		due = append(due, t)
+
+		if len(due) > job.options.CatchUp+1 {
+			if missed == 0 {
+				firstMissed = due[0]
+			}
+
+			due = due[1:]
+			missed++
+		}
+
+		// after a very long delay the remaining activations up to now are skipped
+
+		if missed+len(due) >= schedulerMaxDue {
+			t = job.schedule.Next(now)
+
+			break
+		}
+
+		t = job.schedule.Next(t)
+	}
+
+	job.next = t
+
+	if len(due) == 0 {
+		return
+	}
+
+	if missed > 0 {
+		Warn("Job %s missed %d activations", job.name, missed)
+
+		scheduler.record(JobRun{
+			Job:       job.name,
+			Scheduled: firstMissed,
+			Status:    JobMissed,
+			Err:       fmt.Errorf("%d activations missed", missed),
+		})
+	}
+
+	if job.running > 0 {
+		switch job.options.Overlap {
+		case OverlapSkip:
+			for _, scheduled := range due {
+				scheduler.record(JobRun{
+					Job:       job.name,
+					Scheduled: scheduled,
+					Status:    JobSkipped,
+				})
+			}
+
+			return
+		case OverlapQueue:
+			job.pending = append(job.pending, due)
+
+			return
+		}
+	}
+
+	scheduler.start(job, due)
+}
+
+// start runs the activations of the job in a goroutine, the caller must hold the lock
+func (scheduler *Scheduler) start(job *scheduledJob, due []time.Time) {
+	job.running++
+
+	scheduler.wg.Add(1)
+	go func() {
+		defer UnregisterGoRoutine(RegisterGoRoutine(1))
+		defer scheduler.wg.Done()
+
+		for _, scheduled := range due {
+			if scheduler.ctx.Err() != nil {
+				break
+			}
+
+			scheduler.execute(job, scheduled)
+		}
+
+		scheduler.mu.Lock()
+		defer scheduler.mu.Unlock()
+
+		job.running--
+
+		if job.running == 0 && len(job.pending) > 0 && scheduler.ctx.Err() == nil {
+			next := job.pending[0]
+			job.pending = job.pending[1:]
+
+			scheduler.start(job, next)
+		}
+	}()
+}
+
+func (scheduler *Scheduler) execute(job *scheduledJob, scheduled time.Time) {
+	run := JobRun{
+		Job:       job.name,
+		Scheduled: scheduled,
+		Start:     time.Now(),
+		Status:    JobOk,
+	}
+
+	run.Err = scheduler.call(job, scheduled)
+	run.End = time.Now()
+
+	if run.Err != nil {
+		run.Status = JobFailed
+
+		Error(fmt.Errorf("Job %s failed: %w", job.name, run.Err))
+	}
+
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	scheduler.record(run)
+}
+
+func (scheduler *Scheduler) call(job *scheduledJob, scheduled time.Time) (err error) {
+	defer RecoverPanic(&err)
+
+	return job.fn(scheduler.ctx, scheduled)
+}
+
+// record adds the run to the history, the caller must hold the lock
+func (scheduler *Scheduler) record(run JobRun) {
+	scheduler.history = append(scheduler.history, run)
+
+	if len(scheduler.history) > schedulerHistoryLen {
+		scheduler.history = scheduler.history[len(scheduler.history)-schedulerHistoryLen:]
+	}
+}
+
+// Runs returns the latest runs of all jobs, the oldest first
+func (scheduler *Scheduler) Runs() []JobRun {
+	scheduler.mu.Lock()
+	defer scheduler.mu.Unlock()
+
+	runs := make([]JobRun, len(scheduler.history))
+	copy(runs, scheduler.history)
+
+	return runs
+}
+
+// History returns the latest runs of all jobs as table
+func (scheduler *Scheduler) History() *StringTable {
+	st := NewStringTable()
+	st.AddCols("Job", "Scheduled", "Started", "Duration", "Status", "Error")
+
+	for _, run := range scheduler.Runs() {
+		started := ""
+		duration := ""
+
+		if !run.Start.IsZero() {
+			started = run.Start.Format(SortedDateTimeMilliMask)
+			duration = run.End.Sub(run.Start).String()
+		}
+
+		errMsg := ""
+		if run.Err != nil {
+			errMsg = run.Err.Error()
+		}
+
+		st.AddCols(run.Job, run.Scheduled.Format(SortedDateTimeMilliMask), started, duration, string(run.Status), errMsg)
+	}
+
+	return st
+}
@@ -0,0 +1,219 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// testSchedule activates at multiples of a sub-second interval
+type testSchedule struct {
+	interval time.Duration
+}
+
+func (schedule *testSchedule) Next(t time.Time) time.Time {
+	return t.Truncate(schedule.interval).Add(schedule.interval)
+}
+
+func countRuns(runs []JobRun, status JobStatus) int {
+	count := 0
+	for _, run := range runs {
+		if run.Status == status {
+			count++
+		}
+	}
+
+	return count
+}
+
+func TestScheduler(t *testing.T) {
+	scheduler := NewScheduler(nil)
+
+	var calls atomic.Int32
+
+	require.NoError(t, scheduler.AddScheduleJob("ok", &testSchedule{20 * time.Millisecond}, JobOptions{}, func(ctx context.Context, scheduled time.Time) error {
+		calls.Add(1)
+
+		return nil
+	}))
+	require.NoError(t, scheduler.AddScheduleJob("fail", &testSchedule{20 * time.Millisecond}, JobOptions{}, func(ctx context.Context, scheduled time.Time) error {
+		return fmt.Errorf("failed")
+	}))
+	require.NoError(t, scheduler.AddScheduleJob("crash", &testSchedule{20 * time.Millisecond}, JobOptions{}, func(ctx context.Context, scheduled time.Time) error {
+		panic("crash")
+	}))
+
+	var errJob *ErrSchedulerJob
+	require.ErrorAs(t, scheduler.AddJob("ok", "@every 1s", JobOptions{}, nil), &errJob)
+
+	scheduler.Start()
+
+	time.Sleep(110 * time.Millisecond)
+
+	scheduler.Stop()
+
+	runs := scheduler.Runs()
+
+	require.GreaterOrEqual(t, calls.Load(), int32(3))
+	require.Equal(t, int(calls.Load()), countRuns(runs, JobOk))
+	require.GreaterOrEqual(t, countRuns(runs, JobFailed), 6)
+
+	var errPanic *ErrPanic
+	for _, run := range runs {
+		if run.Job == "crash" {
+			require.ErrorAs(t, run.Err, &errPanic)
+		}
+	}
+
+	history := scheduler.History()
+	require.Equal(t, len(runs)+1, history.Rows())
+	require.True(t, strings.Contains(history.Table(), "failed"))
+
+	// no more runs after stop
+
+	lastCalls := calls.Load()
+
+	time.Sleep(50 * time.Millisecond)
+
+	require.Equal(t, lastCalls, calls.Load())
+}
+
+func TestSchedulerOverlap(t *testing.T) {
+	tests := []struct {
+		overlap OverlapPolicy
+	}{
+		{OverlapSkip},
+		{OverlapQueue},
+		{OverlapParallel},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.overlap.String(), func(t *testing.T) {
+			scheduler := NewScheduler(nil)
+
+			release := make(chan struct{})
+
+			var running atomic.Int32
+			var maxRunning atomic.Int32
+			var calls atomic.Int32
+
+			require.NoError(t, scheduler.AddScheduleJob("job", &testSchedule{20 * time.Millisecond}, JobOptions{Overlap: tt.overlap}, func(ctx context.Context, scheduled time.Time) error {
+				n := running.Add(1)
+				defer running.Add(-1)
+
+				if n > maxRunning.Load() {
+					maxRunning.Store(n)
+				}
+
+				if calls.Add(1) == 1 {
+					<-release
+				}
+
+				return nil
+			}))
+
+			scheduler.Start()
+
+			// the first run blocks while the next activations are due
+
+			time.Sleep(110 * time.Millisecond)
+
+			close(release)
+
+			time.Sleep(30 * time.Millisecond)
+
+			scheduler.Stop()
+
+			runs := scheduler.Runs()
+
+			switch tt.overlap {
+			case OverlapSkip:
+				require.Equal(t, int32(1), maxRunning.Load())
+				require.GreaterOrEqual(t, countRuns(runs, JobSkipped), 3)
+			case OverlapQueue:
+				require.Equal(t, int32(1), maxRunning.Load())
+				require.Equal(t, 0, countRuns(runs, JobSkipped))
+				require.GreaterOrEqual(t, calls.Load(), int32(4))
+			case OverlapParallel:
+				require.Greater(t, maxRunning.Load(), int32(1))
+				require.Equal(t, 0, countRuns(runs, JobSkipped))
+			}
+		})
+	}
+}
+
+func TestSchedulerCatchUp(t *testing.T) {
+	scheduler := NewScheduler(nil)
+
+	var mu sync.Mutex
+	var scheduled []time.Time
+
+	now := time.Now()
+
+	require.NoError(t, scheduler.AddScheduleJob("job", &testSchedule{time.Second}, JobOptions{CatchUp: 2, LastRun: now.Add(-10 * time.Second)}, func(ctx context.Context, t time.Time) error {
+		mu.Lock()
+		defer mu.Unlock()
+
+		scheduled = append(scheduled, t)
+
+		return nil
+	}))
+
+	scheduler.Start()
+
+	time.Sleep(50 * time.Millisecond)
+
+	scheduler.Stop()
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	// the latest activation and 2 missed ones are run in order, a further activation may follow at a second boundary
+
+	require.GreaterOrEqual(t, len(scheduled), 3)
+	require.False(t, scheduled[2].Before(now.Truncate(time.Second)))
+	require.Equal(t, scheduled[2].Add(-time.Second), scheduled[1])
+	require.Equal(t, scheduled[2].Add(-2*time.Second), scheduled[0])
+
+	// the missed activations are recorded by the first one
+
+	runs := scheduler.Runs()
+	require.Equal(t, JobMissed, runs[0].Status)
+	require.Equal(t, now.Add(-9*time.Second).Truncate(time.Second), runs[0].Scheduled)
+	require.ErrorContains(t, runs[0].Err, "activations missed")
+}
+
+func TestSchedulerShutdown(t *testing.T) {
+	scheduler := NewScheduler(nil)
+	scheduler.eventManager = NewEventManager()
+
+	started := make(chan struct{}, 1)
+
+	require.NoError(t, scheduler.AddScheduleJob("job", &testSchedule{10 * time.Millisecond}, JobOptions{}, func(ctx context.Context, scheduled time.Time) error {
+		select {
+		case started <- struct{}{}:
+		default:
+		}
+
+		<-ctx.Done()
+
+		return ctx.Err()
+	}))
+
+	scheduler.Start()
+
+	<-started
+
+	// the running job is canceled by the shutdown and the scheduler waits for it
+
+	require.NoError(t, Publish(scheduler.eventManager, EventShutdown{}))
+
+	runs := scheduler.Runs()
+	require.NotEmpty(t, runs)
+	require.ErrorIs(t, runs[len(runs)-1].Err, context.Canceled)
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const (
+	ENV_PREFIX   = "env:"
+	FILE_PREFIX  = "file:"
+	VAULT_PREFIX = "vault:"
+)
+
+// SecretProvider resolves the reference part of a flag value "<prefix><reference>" to the secret value
+type SecretProvider interface {
+	Resolve(reference string) (string, error)
+}
+
+type SecretProviderFunc func(reference string) (string, error)
+
+func (fn SecretProviderFunc) Resolve(reference string) (string, error) {
+	return fn(reference)
+}
+
+type ErrSecretNotFound struct {
+	Reference string
+}
+
+func (e *ErrSecretNotFound) Error() string {
+	return fmt.Sprintf("secret not found: %s", e.Reference)
+}
+
+var (
+	secretProviders      = make(map[string]SecretProvider)
+	secretProvidersMutex sync.Mutex
+)
+
+func init() {
+	RegisterSecretProvider(SECRET_PREFIX, SecretProviderFunc(func(reference string) (string, error) {
+		return Secret(SECRET_PREFIX + reference)
+	}))
+
+	RegisterSecretProvider(ENV_PREFIX, SecretProviderFunc(func(reference string) (string, error) {
+		value, ok := os.LookupEnv(reference)
+		if !ok {
+			return "", fmt.Errorf("ENV variable cannot be evaluated: %s", ENV_PREFIX+reference)
+		}
+
+		return value, nil
+	}))
+
+	RegisterSecretProvider(FILE_PREFIX, SecretProviderFunc(func(reference string) (string, error) {
+		ba, err := os.ReadFile(reference)
+		if err != nil {
+			return "", err
+		}
+
+		return strings.TrimSpace(string(ba)), nil
+	}))
+
+	RegisterSecretProvider(VAULT_PREFIX, SecretProviderFunc(func(reference string) (string, error) {
+		if FlagCfgVaultFile == nil {
+			return "", &ErrFlagNotDefined{Name: FlagNameCfgVaultFile}
+		}
+
+		vault, err := LoadSecretVault(*FlagCfgVaultFile)
+		if err != nil {
+			return "", err
+		}
+
+		return vault.Get(reference)
+	}))
+}
+
+// RegisterSecretProvider registers a provider for all flag values starting with the prefix (e.g. "azkv:")
+func RegisterSecretProvider(prefix string, provider SecretProvider) {
+	secretProvidersMutex.Lock()
+	defer secretProvidersMutex.Unlock()
+
+	secretProviders[prefix] = provider
+}
+
+func secretProviderOf(value string) (string, SecretProvider) {
+	secretProvidersMutex.Lock()
+	defer secretProvidersMutex.Unlock()
+
+	// the longest matching prefix wins, so a provider "azkv:" is not hidden by a provider "az:"
+
+	prefixes := SortedKeys(secretProviders)
+	slices.SortStableFunc(prefixes, func(a, b string) int {
+		return len(b) - len(a)
+	})
+
+	for _, prefix := range prefixes {
+		if strings.HasPrefix(value, prefix) {
+			// "file://..." is a URI and no file reference
+
+			if prefix == FILE_PREFIX && strings.HasPrefix(value, FILE_PREFIX+"//") {
+				return "", nil
+			}
+
+			return prefix, secretProviders[prefix]
+		}
+	}
+
+	return "", nil
+}
+
+// IsSecretReference returns true if the value must be resolved by a registered SecretProvider
+func IsSecretReference(value string) bool {
+	_, provider := secretProviderOf(value)
+
+	return provider != nil
+}
+
+// ResolveSecret resolves the value by the SecretProvider registered for its prefix.
+// Values without a registered prefix are returned unchanged.
+func ResolveSecret(value string) (string, error) {
+	prefix, provider := secretProviderOf(value)
+	if provider == nil {
+		return value, nil
+	}
+
+	DebugFunc(prefix)
+
+	secret, err := provider.Resolve(value[len(prefix):])
+	if err != nil {
+		return "", fmt.Errorf("cannot resolve secret reference %s...: %w", prefix, err)
+	}
+
+	return secret, nil
+}
+
+// SecretVault is a local JSON file with named secrets which are stored encrypted by the secret key
+type SecretVault struct {
+	Filename string
+	Secrets  map[string]string
+	mu       sync.Mutex
+}
+
+func NewSecretVault(filename string) *SecretVault {
+	return &SecretVault{
+		Filename: filename,
+		Secrets:  make(map[string]string),
+	}
+}
+
+func LoadSecretVault(filename string) (*SecretVault, error) {
+	vault := NewSecretVault(filename)
+
+	if !FileExists(filename) {
+		return vault, nil
+	}
+
+	ba, err := os.ReadFile(filename)
+	if Error(err) {
+		return nil, err
+	}
+
+	err = json.Unmarshal(ba, &vault.Secrets)
+	if Error(err) {
+		return nil, err
+	}
+
+	return vault, nil
+}
+
+func (vault *SecretVault) Names() []string {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	return SortedKeys(vault.Secrets)
+}
+
+func (vault *SecretVault) Get(name string) (string, error) {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	value, ok := vault.Secrets[name]
+	if !ok {
+		return "", &ErrSecretNotFound{Reference: VAULT_PREFIX + name}
+	}
+
+	return Secret(value)
+}
+
+func (vault *SecretVault) Put(name string, value string) error {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	encrypted, err := Secret(value)
+	if Error(err) {
+		return err
+	}
+
+	vault.Secrets[name] = encrypted
+
+	return nil
+}
+
+func (vault *SecretVault) Remove(name string) {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	delete(vault.Secrets, name)
+}
+
+func (vault *SecretVault) Save() error {
+	vault.mu.Lock()
+	defer vault.mu.Unlock()
+
+	err := FileBackup(vault.Filename)
+	if Error(err) {
+		return err
+	}
+
+	// only the owner is allowed to read the vault
+
+	return WriteJsonFile(vault.Filename, vault.Secrets, CalcFileMode(FilePermission{Read: true, Write: true}, FilePermission{}, FilePermission{}))
+}
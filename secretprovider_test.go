@@ -0,0 +1,105 @@
+package common
+
+import (
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSecretProviders(t *testing.T) {
+	t.Setenv("SECRETKEY", "0123456789abcdef")
+	t.Setenv("SECRETPROVIDER_TEST", "env secret")
+
+	dir := t.TempDir()
+
+	file := filepath.Join(dir, "secret.txt")
+	require.NoError(t, os.WriteFile(file, []byte("file secret\n"), DefaultFileMode))
+
+	encrypted, err := Secret("aes secret")
+	require.NoError(t, err)
+
+	for value, expected := range map[string]string{
+		"plain":                      "plain",
+		"file:///etc/hosts":          "file:///etc/hosts",
+		"file:no-such-file":          "",
+		"env:SECRETPROVIDER_TEST":    "env secret",
+		FILE_PREFIX + file:           "file secret",
+		encrypted:                    "aes secret",
+		"custom:whatever":            "custom:whatever",
+		"env:SECRETPROVIDER_UNKNOWN": "",
+	} {
+		secret, err := ResolveSecret(value)
+		if expected == "" {
+			require.Error(t, err)
+
+			continue
+		}
+
+		require.NoError(t, err)
+		require.Equal(t, expected, secret)
+	}
+
+	RegisterSecretProvider("custom:", SecretProviderFunc(func(reference string) (string, error) {
+		return "custom " + reference, nil
+	}))
+	defer func() {
+		secretProvidersMutex.Lock()
+		defer secretProvidersMutex.Unlock()
+
+		delete(secretProviders, "custom:")
+	}()
+
+	require.True(t, IsSecretReference("custom:whatever"))
+
+	secret, err := ResolveSecret("custom:whatever")
+	require.NoError(t, err)
+	require.Equal(t, "custom whatever", secret)
+
+	// the longest prefix wins
+
+	RegisterSecretProvider("custom:long:", SecretProviderFunc(func(reference string) (string, error) {
+		return "long " + reference, nil
+	}))
+	defer func() {
+		secretProvidersMutex.Lock()
+		defer secretProvidersMutex.Unlock()
+
+		delete(secretProviders, "custom:long:")
+	}()
+
+	for i := 0; i < 10; i++ {
+		secret, err = ResolveSecret("custom:long:whatever")
+		require.NoError(t, err)
+		require.Equal(t, "long whatever", secret)
+	}
+}
+
+func TestSecretVault(t *testing.T) {
+	t.Setenv("SECRETKEY", "0123456789abcdef")
+
+	filename := filepath.Join(t.TempDir(), "test.vault")
+
+	vault, err := LoadSecretVault(filename)
+	require.NoError(t, err)
+
+	require.NoError(t, vault.Put("db", "db secret"))
+	require.NoError(t, vault.Save())
+
+	ba, err := os.ReadFile(filename)
+	require.NoError(t, err)
+	require.NotContains(t, string(ba), "db secret")
+
+	oldVaultFile := FlagCfgVaultFile
+	FlagCfgVaultFile = &filename
+	defer func() {
+		FlagCfgVaultFile = oldVaultFile
+	}()
+
+	secret, err := ResolveSecret(VAULT_PREFIX + "db")
+	require.NoError(t, err)
+	require.Equal(t, "db secret", secret)
+
+	_, err = ResolveSecret(VAULT_PREFIX + "unknown")
+	require.ErrorAs(t, err, new(*ErrSecretNotFound))
+}
@@ -22,6 +22,10 @@ type SqlDb struct {
 	tx          *sql.Tx
 	txCtx       context.Context
 	txCtxCancel context.CancelFunc
+
+	// RetryPolicy and CircuitBreaker are optional for the ping of Open
+	RetryPolicy    *common.RetryPolicy
+	CircuitBreaker *common.CircuitBreaker
 }
 
 const (
@@ -76,6 +80,11 @@ func (sqlDb *SqlDb) currentDb() dbintf {
 }
 
 func (sqlDb *SqlDb) Open() error {
+	return sqlDb.OpenContext(context.Background())
+}
+
+// OpenContext opens the database like Open, the retries of the ping stop when ctx is done
+func (sqlDb *SqlDb) OpenContext(ctx context.Context) error {
 	if sqlDb.conn == nil {
 		var err error
 
@@ -95,20 +104,25 @@ func (sqlDb *SqlDb) Open() error {
 		}
 	}
 
-	ctx := context.Background()
+	_, err := common.RetryWithBreaker(ctx, sqlDb.RetryPolicy, sqlDb.CircuitBreaker, func(ctx context.Context) (struct{}, error) {
+		return struct{}{}, sqlDb.ping(ctx)
+	})
+	if common.Error(err) {
+		return err
+	}
+
+	return nil
+}
+
+func (sqlDb *SqlDb) ping(ctx context.Context) error {
 	if *FlagDbPingTimeout != 0 {
 		var cancel context.CancelFunc
 
-		ctx, cancel = context.WithTimeout(context.Background(), common.MillisecondToDuration(*FlagDbPingTimeout))
+		ctx, cancel = context.WithTimeout(ctx, common.MillisecondToDuration(*FlagDbPingTimeout))
 		defer cancel()
 	}
 
-	err := sqlDb.conn.PingContext(ctx)
-	if common.Error(err) {
-		return err
-	}
-
-	return nil
+	return sqlDb.conn.PingContext(ctx)
 }
 
 func (sqlDb *SqlDb) Close() error {
@@ -2,48 +2,149 @@ package common
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 )
 
+// TasksOptions configures Tasks. Zero values are the defaults of NewTasks.
+type TasksOptions struct {
+	// MaxParallel is the maximum count of concurrently running tasks, 0 is unlimited
+	MaxParallel int
+	// FailFast cancels the context of all tasks after the first failed task
+	FailFast bool
+}
+
+var errTasksFailFast = errors.New("canceled by a failed task")
+
 type Tasks struct {
 	sync.Mutex
-	Wg   sync.WaitGroup
-	Ctx  context.Context
-	Errs []error
+	Wg       sync.WaitGroup
+	Ctx      context.Context
+	Errs     []error
+	parent   context.Context
+	cancel   context.CancelCauseFunc
+	failFast bool
+	sem      chan struct{}
+	count    int
+}
+
+// ErrTask is the error of a named task
+type ErrTask struct {
+	Name string
+	Err  error
+}
+
+func (e *ErrTask) Error() string {
+	return fmt.Sprintf("task %s: %v", e.Name, e.Err)
+}
+
+func (e *ErrTask) Unwrap() error {
+	return e.Err
 }
 
 func NewTasks(ctx context.Context) *Tasks {
-	return &Tasks{
-		Ctx: ctx,
+	return NewTasksWithOptions(ctx, TasksOptions{})
+}
+
+// NewTasksWithOptions creates Tasks with limited parallelism and fail-fast cancellation
+func NewTasksWithOptions(ctx context.Context, options TasksOptions) *Tasks {
+	tasks := &Tasks{
+		parent:   ctx,
+		failFast: options.FailFast,
+	}
+
+	tasks.Ctx, tasks.cancel = context.WithCancelCause(ctx)
+
+	if options.MaxParallel > 0 {
+		tasks.sem = make(chan struct{}, options.MaxParallel)
 	}
+
+	return tasks
 }
 
 type TaskFunc func(ctx context.Context) error
 
+// Add runs the task with the name "#<index>"
 func (tasks *Tasks) Add(fn TaskFunc) {
+	tasks.add("", fn)
+}
+
+// AddNamed runs the task, a failure is reported as ErrTask with the name. A panic of the task is converted to an ErrPanic.
+func (tasks *Tasks) AddNamed(name string, fn TaskFunc) {
+	tasks.add(name, fn)
+}
+
+func (tasks *Tasks) add(name string, fn TaskFunc) {
+	tasks.Lock()
+	if name == "" {
+		name = fmt.Sprintf("#%d", tasks.count)
+	}
+	tasks.count++
+	ctx := tasks.Ctx
+	cancel := tasks.cancel
+	tasks.Unlock()
+
 	tasks.Wg.Add(1)
 
 	go func() {
 		defer tasks.Wg.Done()
 
-		err := fn(tasks.Ctx)
+		if tasks.sem != nil {
+			select {
+			case tasks.sem <- struct{}{}:
+				defer func() {
+					<-tasks.sem
+				}()
+			case <-ctx.Done():
+			}
+		}
+
+		var err error
+
+		if ctx.Err() != nil {
+			// after a fail-fast cancellation the waiting tasks are not started anymore
+
+			if errors.Is(context.Cause(ctx), errTasksFailFast) {
+				return
+			}
+
+			err = ctx.Err()
+		} else {
+			err = tasks.run(ctx, fn)
+		}
 
 		if err != nil {
+			DebugFunc("%s: %v", name, err)
+
 			tasks.Lock()
 			defer tasks.Unlock()
 
-			tasks.Errs = append(tasks.Errs, err)
+			tasks.Errs = append(tasks.Errs, &ErrTask{Name: name, Err: err})
+
+			if tasks.failFast {
+				cancel(errTasksFailFast)
+			}
 		}
 	}()
 }
 
+func (tasks *Tasks) run(ctx context.Context, fn TaskFunc) (err error) {
+	defer RecoverPanic(&err)
+
+	return fn(ctx)
+}
+
+// Wait waits for all tasks and returns the joined errors of all failed tasks.
+// Tasks added afterwards run with a new context, a fail-fast cancellation does not affect them.
 func (tasks *Tasks) Wait() error {
 	tasks.Wg.Wait()
 
-	if len(tasks.Errs) > 0 {
-		return fmt.Errorf("%d errors occured", len(tasks.Errs))
-	}
+	tasks.Lock()
+	defer tasks.Unlock()
+
+	tasks.cancel(nil)
+	tasks.Ctx, tasks.cancel = context.WithCancelCause(tasks.parent)
 
-	return nil
+	return errors.Join(tasks.Errs...)
 }
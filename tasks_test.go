@@ -0,0 +1,129 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestTasks(t *testing.T) {
+	tasks := NewTasks(context.Background())
+
+	for i := range 5 {
+		tasks.Add(func(ctx context.Context) error {
+			if i%2 == 1 {
+				return fmt.Errorf("failed %d", i)
+			}
+
+			return nil
+		})
+	}
+
+	tasks.AddNamed("crash", func(ctx context.Context) error {
+		panic("crash")
+	})
+
+	err := tasks.Wait()
+	require.Error(t, err)
+	require.Len(t, tasks.Errs, 3)
+
+	var errPanic *ErrPanic
+	require.ErrorAs(t, err, &errPanic)
+
+	names := make(map[string]bool)
+	for _, e := range tasks.Errs {
+		var errTask *ErrTask
+		require.ErrorAs(t, e, &errTask)
+
+		names[errTask.Name] = true
+	}
+
+	require.Equal(t, map[string]bool{"#1": true, "#3": true, "crash": true}, names)
+}
+
+func TestTasksMaxParallel(t *testing.T) {
+	tasks := NewTasksWithOptions(context.Background(), TasksOptions{MaxParallel: 2})
+
+	var running atomic.Int32
+	var maxRunning atomic.Int32
+
+	for range 10 {
+		tasks.Add(func(ctx context.Context) error {
+			n := running.Add(1)
+			defer running.Add(-1)
+
+			for {
+				m := maxRunning.Load()
+				if n <= m || maxRunning.CompareAndSwap(m, n) {
+					break
+				}
+			}
+
+			time.Sleep(10 * time.Millisecond)
+
+			return nil
+		})
+	}
+
+	require.NoError(t, tasks.Wait())
+	require.Equal(t, int32(2), maxRunning.Load())
+}
+
+func TestTasksFailFast(t *testing.T) {
+	tasks := NewTasksWithOptions(context.Background(), TasksOptions{MaxParallel: 2, FailFast: true})
+
+	var started atomic.Int32
+
+	waiting := make(chan struct{})
+
+	tasks.AddNamed("fail", func(ctx context.Context) error {
+		started.Add(1)
+
+		<-waiting
+
+		return fmt.Errorf("failed")
+	})
+
+	tasks.AddNamed("wait", func(ctx context.Context) error {
+		started.Add(1)
+
+		close(waiting)
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(time.Second):
+			return nil
+		}
+	})
+
+	time.Sleep(50 * time.Millisecond)
+
+	// waiting tasks are not started after the first failure
+
+	tasks.AddNamed("skipped", func(ctx context.Context) error {
+		started.Add(1)
+
+		return nil
+	})
+
+	err := tasks.Wait()
+	require.ErrorIs(t, err, context.Canceled)
+	require.ErrorContains(t, err, "task fail: failed")
+	require.Equal(t, int32(2), started.Load())
+
+	// tasks can be added again after Wait
+
+	tasks.AddNamed("again", func(ctx context.Context) error {
+		started.Add(1)
+
+		return ctx.Err()
+	})
+
+	require.Error(t, tasks.Wait())
+	require.Equal(t, int32(3), started.Load())
+	require.Len(t, tasks.Errs, 2)
+}
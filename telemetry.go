@@ -9,6 +9,7 @@ type EventTelemetry struct {
 	IsTelemetryRequest bool
 	Ctx                context.Context
 	Title              string
+	RequestId          string
 	Start              time.Time
 	End                time.Time
 	Err                string
@@ -0,0 +1,265 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// WorkerPoolOptions configures a WorkerPool. Zero values are the defaults.
+type WorkerPoolOptions struct {
+	// Workers is the count of concurrently running tasks, default is the "concurrent.limit" flag
+	Workers int
+	// QueueLen is the count of tasks waiting for a free worker
+	QueueLen int
+	// TaskTimeout cancels the context of a task after the timeout, 0 is no timeout
+	TaskTimeout time.Duration
+}
+
+// WorkerPoolStats are the counters of a WorkerPool
+type WorkerPoolStats struct {
+	Queued    int64 `json:"queued"`
+	Running   int64 `json:"running"`
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+	Rejected  int64 `json:"rejected"`
+	Panics    int64 `json:"panics"`
+}
+
+// WorkerResult is the result of a task
+type WorkerResult[In any, Out any] struct {
+	Input  In
+	Output Out
+	Err    error
+}
+
+type ErrWorkerPoolFull struct {
+	QueueLen int
+}
+
+func (e *ErrWorkerPoolFull) Error() string {
+	return fmt.Sprintf("worker pool queue is full: %d", e.QueueLen)
+}
+
+var ErrWorkerPoolClosed = fmt.Errorf("worker pool is closed")
+
+type workerTask[In any, Out any] struct {
+	ctx    context.Context
+	input  In
+	result chan WorkerResult[In, Out]
+}
+
+// WorkerPool runs the tasks by a fixed count of workers. Tasks wait in a bounded queue for a free worker.
+type WorkerPool[In any, Out any] struct {
+	ctx       context.Context
+	fn        func(ctx context.Context, input In) (Out, error)
+	timeout   time.Duration
+	queueLen  int
+	queue     chan *workerTask[In, Out]
+	slots     chan struct{}
+	wg        sync.WaitGroup
+	mu        sync.RWMutex
+	closed    bool
+	queued    atomic.Int64
+	running   atomic.Int64
+	completed atomic.Int64
+	failed    atomic.Int64
+	rejected  atomic.Int64
+	panics    atomic.Int64
+}
+
+// NewWorkerPool starts the workers which run fn. After ctx is done all waiting and new tasks fail with the error of ctx.
+// Close must be called to stop the workers.
+func NewWorkerPool[In any, Out any](ctx context.Context, options WorkerPoolOptions, fn func(ctx context.Context, input In) (Out, error)) *WorkerPool[In, Out] {
+	workers := options.Workers
+	if workers <= 0 {
+		workers = Max(1, *FlagConcurrentLimit)
+	}
+
+	queueLen := Max(0, options.QueueLen)
+
+	// a slot is taken by every queued or running task, so the queue channel never blocks
+
+	pool := &WorkerPool[In, Out]{
+		ctx:      ctx,
+		fn:       fn,
+		timeout:  options.TaskTimeout,
+		queueLen: queueLen,
+		queue:    make(chan *workerTask[In, Out], workers+queueLen),
+		slots:    make(chan struct{}, workers+queueLen),
+	}
+
+	for range workers {
+		pool.wg.Add(1)
+		go func() {
+			defer pool.wg.Done()
+
+			for task := range pool.queue {
+				pool.queued.Add(-1)
+
+				pool.run(task)
+
+				<-pool.slots
+			}
+		}()
+	}
+
+	return pool
+}
+
+func (pool *WorkerPool[In, Out]) run(task *workerTask[In, Out]) {
+	result := WorkerResult[In, Out]{
+		Input: task.input,
+	}
+
+	defer func() {
+		if result.Err != nil {
+			pool.failed.Add(1)
+		} else {
+			pool.completed.Add(1)
+		}
+
+		task.result <- result
+	}()
+
+	// the task is canceled by its own context and by the context of the pool
+
+	ctx, cancel := context.WithCancel(task.ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(pool.ctx, cancel)
+	defer stop()
+
+	if pool.timeout > 0 {
+		ctx, cancel = context.WithTimeout(ctx, pool.timeout)
+		defer cancel()
+	}
+
+	if ctx.Err() != nil {
+		result.Err = ctx.Err()
+
+		return
+	}
+
+	pool.running.Add(1)
+	defer pool.running.Add(-1)
+
+	result.Output, result.Err = pool.call(ctx, task.input)
+
+	if _, ok := result.Err.(*ErrPanic); ok {
+		pool.panics.Add(1)
+
+		Error(result.Err)
+	}
+}
+
+func (pool *WorkerPool[In, Out]) call(ctx context.Context, input In) (out Out, err error) {
+	defer RecoverPanic(&err)
+
+	return pool.fn(ctx, input)
+}
+
+func (pool *WorkerPool[In, Out]) submit(ctx context.Context, input In, wait bool) (<-chan WorkerResult[In, Out], error) {
+	pool.mu.RLock()
+	defer pool.mu.RUnlock()
+
+	if pool.closed {
+		pool.rejected.Add(1)
+
+		return nil, ErrWorkerPoolClosed
+	}
+
+	if pool.ctx.Err() != nil {
+		pool.rejected.Add(1)
+
+		return nil, pool.ctx.Err()
+	}
+
+	if !wait {
+		select {
+		case pool.slots <- struct{}{}:
+		default:
+			pool.rejected.Add(1)
+
+			return nil, &ErrWorkerPoolFull{QueueLen: pool.queueLen}
+		}
+	} else {
+		select {
+		case pool.slots <- struct{}{}:
+		case <-ctx.Done():
+			pool.rejected.Add(1)
+
+			return nil, ctx.Err()
+		case <-pool.ctx.Done():
+			pool.rejected.Add(1)
+
+			return nil, pool.ctx.Err()
+		}
+	}
+
+	task := &workerTask[In, Out]{
+		ctx:    ctx,
+		input:  input,
+		result: make(chan WorkerResult[In, Out], 1),
+	}
+
+	pool.queued.Add(1)
+	pool.queue <- task
+
+	return task.result, nil
+}
+
+// Submit queues the task and blocks while the queue is full until ctx or the pool is done.
+// The result is sent to the returned channel.
+func (pool *WorkerPool[In, Out]) Submit(ctx context.Context, input In) (<-chan WorkerResult[In, Out], error) {
+	return pool.submit(ctx, input, true)
+}
+
+// TrySubmit queues the task or rejects it with ErrWorkerPoolFull if the queue is full
+func (pool *WorkerPool[In, Out]) TrySubmit(ctx context.Context, input In) (<-chan WorkerResult[In, Out], error) {
+	return pool.submit(ctx, input, false)
+}
+
+// Stats returns the current counters
+func (pool *WorkerPool[In, Out]) Stats() WorkerPoolStats {
+	return WorkerPoolStats{
+		Queued:    pool.queued.Load(),
+		Running:   pool.running.Load(),
+		Completed: pool.completed.Load(),
+		Failed:    pool.failed.Load(),
+		Rejected:  pool.rejected.Load(),
+		Panics:    pool.panics.Load(),
+	}
+}
+
+// Close rejects new tasks and waits until all queued tasks are done
+func (pool *WorkerPool[In, Out]) Close() {
+	DebugFunc()
+
+	pool.mu.Lock()
+
+	if pool.closed {
+		pool.mu.Unlock()
+
+		return
+	}
+
+	pool.closed = true
+	close(pool.queue)
+
+	pool.mu.Unlock()
+
+	pool.wg.Wait()
+}
+
+// WorkerFunc is a task of a pool created by NewFuncWorkerPool
+type WorkerFunc func(ctx context.Context) error
+
+// NewFuncWorkerPool creates a pool which runs WorkerFuncs, e.g. for WorkerPoolHandler
+func NewFuncWorkerPool(ctx context.Context, options WorkerPoolOptions) *WorkerPool[WorkerFunc, struct{}] {
+	return NewWorkerPool(ctx, options, func(ctx context.Context, fn WorkerFunc) (struct{}, error) {
+		return struct{}{}, fn(ctx)
+	})
+}
@@ -0,0 +1,224 @@
+package common
+
+import (
+	"context"
+	"fmt"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWorkerPool(t *testing.T) {
+	pool := NewWorkerPool(context.Background(), WorkerPoolOptions{Workers: 3, QueueLen: 10}, func(ctx context.Context, input int) (int, error) {
+		if input < 0 {
+			return 0, fmt.Errorf("negative: %d", input)
+		}
+
+		return input * input, nil
+	})
+
+	var resultChs []<-chan WorkerResult[int, int]
+
+	for i := -2; i < 8; i++ {
+		resultCh, err := pool.Submit(context.Background(), i)
+		require.NoError(t, err)
+
+		resultChs = append(resultChs, resultCh)
+	}
+
+	for i, resultCh := range resultChs {
+		result := <-resultCh
+
+		input := i - 2
+
+		require.Equal(t, input, result.Input)
+
+		if input < 0 {
+			require.Error(t, result.Err)
+		} else {
+			require.NoError(t, result.Err)
+			require.Equal(t, input*input, result.Output)
+		}
+	}
+
+	pool.Close()
+
+	stats := pool.Stats()
+	require.Equal(t, int64(8), stats.Completed)
+	require.Equal(t, int64(2), stats.Failed)
+	require.Equal(t, int64(0), stats.Queued)
+	require.Equal(t, int64(0), stats.Running)
+
+	_, err := pool.Submit(context.Background(), 1)
+	require.ErrorIs(t, err, ErrWorkerPoolClosed)
+}
+
+func TestWorkerPoolFull(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	pool := NewWorkerPool(context.Background(), WorkerPoolOptions{Workers: 1, QueueLen: 1}, func(ctx context.Context, input int) (int, error) {
+		started <- struct{}{}
+		<-release
+
+		return input, nil
+	})
+
+	// the first task blocks the worker, the second waits in the queue
+
+	first, err := pool.TrySubmit(context.Background(), 1)
+	require.NoError(t, err)
+
+	<-started
+
+	second, err := pool.TrySubmit(context.Background(), 2)
+	require.NoError(t, err)
+
+	_, err = pool.TrySubmit(context.Background(), 3)
+	var errFull *ErrWorkerPoolFull
+	require.ErrorAs(t, err, &errFull)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = pool.Submit(ctx, 4)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+
+	stats := pool.Stats()
+	require.Equal(t, int64(1), stats.Running)
+	require.Equal(t, int64(1), stats.Queued)
+	require.Equal(t, int64(2), stats.Rejected)
+
+	close(release)
+	<-started
+
+	require.Equal(t, 1, (<-first).Output)
+	require.Equal(t, 2, (<-second).Output)
+
+	pool.Close()
+}
+
+func TestWorkerPoolTimeout(t *testing.T) {
+	pool := NewWorkerPool(context.Background(), WorkerPoolOptions{Workers: 1, TaskTimeout: 50 * time.Millisecond}, func(ctx context.Context, input int) (int, error) {
+		<-ctx.Done()
+
+		return 0, ctx.Err()
+	})
+	defer pool.Close()
+
+	resultCh, err := pool.Submit(context.Background(), 1)
+	require.NoError(t, err)
+
+	require.ErrorIs(t, (<-resultCh).Err, context.DeadlineExceeded)
+}
+
+func TestWorkerPoolCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	started := make(chan struct{})
+
+	pool := NewWorkerPool(ctx, WorkerPoolOptions{Workers: 1}, func(ctx context.Context, input int) (int, error) {
+		close(started)
+
+		<-ctx.Done()
+
+		return 0, ctx.Err()
+	})
+	defer pool.Close()
+
+	resultCh, err := pool.Submit(context.Background(), 1)
+	require.NoError(t, err)
+
+	<-started
+
+	cancel()
+
+	require.ErrorIs(t, (<-resultCh).Err, context.Canceled)
+
+	_, err = pool.Submit(context.Background(), 2)
+	require.ErrorIs(t, err, context.Canceled)
+}
+
+func TestWorkerPoolPanic(t *testing.T) {
+	pool := NewWorkerPool(context.Background(), WorkerPoolOptions{Workers: 1}, func(ctx context.Context, input int) (int, error) {
+		var m map[string]int
+
+		m["crash"] = input
+
+		return input, nil
+	})
+	defer pool.Close()
+
+	resultCh, err := pool.Submit(context.Background(), 1)
+	require.NoError(t, err)
+
+	var errPanic *ErrPanic
+	require.ErrorAs(t, (<-resultCh).Err, &errPanic)
+	require.Equal(t, "workerpool_test.go", errPanic.RuntimeInfo.File)
+	require.Contains(t, errPanic.RuntimeInfo.Fn, "TestWorkerPoolPanic")
+
+	require.Equal(t, int64(1), pool.Stats().Panics)
+}
+
+func TestWorkerPoolHandler(t *testing.T) {
+	pool := NewFuncWorkerPool(context.Background(), WorkerPoolOptions{Workers: 1})
+	defer pool.Close()
+
+	var goRoutineRequestId string
+
+	handler := RequestIdHandler(WorkerPoolHandler(pool, func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/panic":
+			panic("crash")
+		case "/written":
+			w.WriteHeader(http.StatusAccepted)
+
+			panic("crash")
+		}
+
+		goRoutineRequestId = GoRoutineRequestId()
+
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Equal(t, w.Header().Get(HEADER_REQUEST_ID), goRoutineRequestId)
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/panic", nil))
+	require.Equal(t, http.StatusInternalServerError, w.Code)
+
+	// an already written response is not overwritten
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/written", nil))
+	require.Equal(t, http.StatusAccepted, w.Code)
+	require.Empty(t, w.Body.String())
+
+	// without queue and a busy worker the request is rejected
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+
+	resultCh, err := pool.TrySubmit(context.Background(), func(ctx context.Context) error {
+		close(started)
+		<-release
+
+		return nil
+	})
+	require.NoError(t, err)
+
+	<-started
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodGet, "/", nil))
+	require.Equal(t, http.StatusServiceUnavailable, w.Code)
+	require.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	close(release)
+	<-resultCh
+}